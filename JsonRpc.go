@@ -3,7 +3,6 @@ package maze
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"reflect"
 	"unicode"
@@ -29,6 +28,7 @@ const (
 type Action struct {
 	name       string
 	callFilter *Filter
+	guards     []*Filter
 	filters    []*Filter
 }
 
@@ -43,14 +43,27 @@ func (a *Action) SetFilters(filters ...Handler) {
 	a.filters = convertHandlers(filters...)
 }
 
+// Require adds filters, like RequireRoles, that are executed before the action filters
+func (a *Action) Require(guards ...Handler) {
+	a.guards = append(a.guards, convertHandlers(guards...)...)
+}
+
 type JsonRpc struct {
 	servicePath string
 	filters     []*Filter
 	actions     []*Action
-	logger      Logger
 }
 
+// NewJsonRpc creates the JSON-RPC endpoints of the service.
+//
+// Deprecated: the logger is not used, since the actions log with IContext.Logger. Use NewJsonRpcService.
 func NewJsonRpc(logger Logger, svc interface{}, filters ...Handler) (*JsonRpc, error) {
+	return NewJsonRpcService(svc, filters...)
+}
+
+// NewJsonRpcService creates the JSON-RPC endpoints of the exported methods of the service.
+// The actions log with IContext.Logger.
+func NewJsonRpcService(svc interface{}, filters ...Handler) (*JsonRpc, error) {
 	v := reflect.ValueOf(svc)
 	t := v.Type()
 	if t.Kind() != reflect.Ptr {
@@ -66,7 +79,6 @@ func NewJsonRpc(logger Logger, svc interface{}, filters ...Handler) (*JsonRpc, e
 		servicePath: t.Name(),
 		actions:     make([]*Action, 0),
 		filters:     convertHandlers(filters...),
-		logger:      logger,
 	}
 
 	// loop through the struct's fields and set the map
@@ -75,8 +87,6 @@ func NewJsonRpc(logger Logger, svc interface{}, filters ...Handler) (*JsonRpc, e
 		if isExported(method.Name) {
 			action := NewAction(method.Name)
 
-			logger.Debugf("Registering JSON-RPC %s/%s", rpc.servicePath, method.Name)
-
 			// validate argument types
 			size := method.Type.NumIn()
 			if size > 3 {
@@ -116,7 +126,7 @@ func NewJsonRpc(logger Logger, svc interface{}, filters ...Handler) (*JsonRpc, e
 					t.Elem().Name(), method.Name, method.Type.Out(1))
 			}
 
-			action.callFilter = &Filter{handler: createCallHandler(logger, payloadType, hasContext, v.Method(i))}
+			action.callFilter = &Filter{handler: createCallHandler(payloadType, hasContext, v.Method(i))}
 			rpc.actions = append(rpc.actions, action)
 		}
 	}
@@ -143,6 +153,11 @@ func (r *JsonRpc) SetActionFilters(actionName string, filters ...Handler) {
 	action.SetFilters(filters...)
 }
 
+// RequireAction attaches permissions to an action, eg: rpc.RequireAction("Delete", maze.RequireRoles("admin"))
+func (r *JsonRpc) RequireAction(actionName string, guards ...Handler) {
+	r.GetAction(actionName).Require(guards...)
+}
+
 func (r *JsonRpc) Build(servicePath string) []*Filter {
 	var prefix string
 	if servicePath == "" {
@@ -161,7 +176,9 @@ func (r *JsonRpc) Build(servicePath string) []*Filter {
 	}
 
 	for _, v := range r.actions {
-		f := v.filters
+		f := make([]*Filter, 0, len(v.guards)+len(v.filters)+1)
+		f = append(f, v.guards...)
+		f = append(f, v.filters...)
 		f = append(f, v.callFilter)
 		// apply rule to the first one
 		f[0].setRule(nil, prefix+v.name)
@@ -183,30 +200,20 @@ var (
 	contextType = reflect.TypeOf((*IContext)(nil)).Elem() // interface type
 )
 
-func createCallHandler(logger Logger, payloadType reflect.Type, hasContext bool, method reflect.Value) Handler {
-	return func(ctx IContext) error {
+func createCallHandler(payloadType reflect.Type, hasContext bool, method reflect.Value) Handler {
+	call := func(ctx IContext) error {
 		w := ctx.GetResponse()
 		r := ctx.GetRequest()
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.Header().Set("Expires", "-1")
 
-		var payload []byte
-		if r.Body != nil {
-			var err error
-			if payload, err = ioutil.ReadAll(r.Body); err != nil {
-				return err
-			}
-		}
-
 		var param reflect.Value
-		var err error
 		if payloadType != nil {
 			// get pointer
 			param = reflect.New(payloadType)
 			// TODO: what happens if args is "null" ???
-			err = json.Unmarshal(payload, param.Interface())
-			if err != nil {
-				logger.Errorf("An error occurred when unmarshalling the call for %s\n\tinput: %s\n\terror: %s", ctx.GetRequest().URL.Path, payload, err)
+			if err := ctx.Payload(param.Interface(), DisallowTrailingData()); err != nil {
+				ctx.Logger().Errorf("An error occurred when unmarshalling the call for %s\n\terror: %s", r.URL.Path, err)
 				return err
 			}
 		}
@@ -237,7 +244,7 @@ func createCallHandler(logger Logger, payloadType reflect.Type, hasContext bool,
 					_, err = ctx.GetResponse().Write(result)
 				}
 				if err != nil {
-					logger.Errorf("An error occurred when marshalling the response from %s\n\tresponse: %v\n\terror: %s", ctx.GetRequest().URL.Path, data, err)
+					ctx.Logger().Errorf("An error occurred when marshalling the response from %s\n\tresponse: %v\n\terror: %s", ctx.GetRequest().URL.Path, data, err)
 					return err
 				}
 			}
@@ -249,6 +256,12 @@ func createCallHandler(logger Logger, payloadType reflect.Type, hasContext bool,
 
 		return nil
 	}
+
+	return func(ctx IContext) error {
+		err := call(ctx)
+		recordRpc(ctx, err)
+		return err
+	}
 }
 
 func isExported(name string) bool {
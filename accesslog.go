@@ -0,0 +1,145 @@
+package maze
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type AccessLogFormat int
+
+const (
+	// StructuredLogFormat logs the request data as logger tags
+	StructuredLogFormat AccessLogFormat = iota
+	// CommonLogFormat logs in the NCSA common log format
+	CommonLogFormat
+	// CombinedLogFormat logs in the NCSA combined log format, that adds the referer and user agent to the common one
+	CombinedLogFormat
+)
+
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+type AccessLogConfig struct {
+	Format AccessLogFormat
+	// SampleRate is the fraction, between 0 and 1, of successful requests that are logged.
+	// Zero logs every request. Requests ending with a status of 400 or above are always logged.
+	SampleRate float64
+	// Exclude are the rules of requests that are not logged, eg: /health
+	Exclude []string
+	// TrustProxy uses the X-Forwarded-For and X-Real-Ip headers to find the remote IP
+	TrustProxy bool
+}
+
+// AccessLog logs every request after it was handled, as defined by the config.
+// If logger is nil, the request logger is used.
+// It should be the first filter so that the latency includes every other filter.
+func AccessLog(logger Logger, cfg AccessLogConfig) Handler {
+	excluded := make([]*Filter, len(cfg.Exclude))
+	for k, v := range cfg.Exclude {
+		excluded[k] = NewFilter(v, nil)
+	}
+
+	return func(c IContext) error {
+		r := c.GetRequest()
+		for _, f := range excluded {
+			if f.IsValid(r) {
+				return c.Proceed()
+			}
+		}
+
+		start := time.Now()
+		err := c.Proceed()
+		latency := time.Since(start)
+
+		rw := c.ResponseWriter()
+		status := responseStatus(c, err)
+		if status < http.StatusBadRequest && cfg.SampleRate > 0 && rand.Float64() >= cfg.SampleRate {
+			return err
+		}
+
+		remoteIP := ClientIP(r, cfg.TrustProxy)
+		log := logger
+		if log == nil {
+			log = c.Logger()
+		}
+		switch cfg.Format {
+		case CommonLogFormat, CombinedLogFormat:
+			line := fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s`,
+				remoteIP,
+				clfValue(username(r)),
+				start.Format(clfTimeLayout),
+				r.Method, r.RequestURI, r.Proto,
+				status,
+				clfSize(rw.Size()),
+			)
+			if cfg.Format == CombinedLogFormat {
+				line += fmt.Sprintf(` "%s" "%s"`, clfValue(r.Referer()), clfValue(r.UserAgent()))
+			}
+			log.Infof("%s", line)
+		default:
+			tags := Tags{
+				"method":     r.Method,
+				"path":       r.URL.Path,
+				"route":      c.Route(),
+				"status":     status,
+				"bytes":      rw.Size(),
+				"latency":    latency.String(),
+				"remote_ip":  remoteIP,
+				"user_agent": r.UserAgent(),
+			}
+			// the request logger is already tagged by SetRequestID
+			if id := c.RequestID(); id != "" && logger != nil {
+				tags["request_id"] = id
+			}
+			log.WithTags(tags).Infof("%s %s %d", r.Method, r.URL.Path, status)
+		}
+
+		return err
+	}
+}
+
+// ClientIP returns the IP of the client.
+// If trustProxy is true, the X-Forwarded-For and X-Real-Ip headers, set by proxies, are used.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			// the first one is the original client
+			if i := strings.Index(xff, ","); i != -1 {
+				xff = xff[:i]
+			}
+			return strings.TrimSpace(xff)
+		}
+		if ip := r.Header.Get("X-Real-Ip"); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func username(r *http.Request) string {
+	if u, _, ok := r.BasicAuth(); ok {
+		return u
+	}
+	return ""
+}
+
+func clfValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+func clfSize(size int64) string {
+	if size == 0 {
+		return "-"
+	}
+	return fmt.Sprint(size)
+}
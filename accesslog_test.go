@@ -0,0 +1,64 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	for _, format := range []AccessLogFormat{StructuredLogFormat, CombinedLogFormat} {
+		logger := newTestLogger()
+		m := NewMaze()
+		m.Push("/*", AccessLog(logger, AccessLogConfig{
+			Format:     format,
+			Exclude:    []string{"/health"},
+			TrustProxy: true,
+		}))
+		m.GET("/greet/:Id", func(c IContext) error {
+			return c.TEXT(http.StatusOK, "hello")
+		})
+		m.GET("/health", func(c IContext) error {
+			return c.TEXT(http.StatusOK, "up")
+		})
+
+		r := httptest.NewRequest("GET", "/greet/1", nil)
+		r.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+		r.Header.Set("User-Agent", "test")
+		m.ServeHTTP(httptest.NewRecorder(), r)
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+
+		lines := logger.Lines()
+		require.Len(t, lines, 1)
+		if format == StructuredLogFormat {
+			tags := lines[0].tags
+			require.Equal(t, "/greet/:Id", tags["route"])
+			require.Equal(t, 200, tags["status"])
+			require.Equal(t, int64(5), tags["bytes"])
+			require.Equal(t, "10.0.0.1", tags["remote_ip"])
+		} else {
+			require.Regexp(t, `^10\.0\.0\.1 - - \[.+\] "GET /greet/1 HTTP/1.1" 200 5 "-" "test"$`, lines[0].msg)
+		}
+	}
+}
+
+func TestAccessLogRequestLogger(t *testing.T) {
+	logger := newTestLogger()
+	m := NewMaze(WithLogger(logger))
+	m.Push("/*", RequestID(RequestIDConfig{}), AccessLog(nil, AccessLogConfig{}))
+	m.GET("/*", func(c IContext) error {
+		return c.TEXT(http.StatusOK, "hello")
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(DefaultRequestIDHeader, "abc-123")
+	m.ServeHTTP(httptest.NewRecorder(), r)
+
+	lines := logger.Lines()
+	line := lines[len(lines)-1]
+	require.Equal(t, 200, line.tags["status"])
+	require.Equal(t, "abc-123", line.tags["request_id"])
+	require.Empty(t, line.repeated)
+}
@@ -0,0 +1,55 @@
+package maze
+
+import (
+	"context"
+)
+
+type attributesKey struct{}
+
+// Key is a typed attribute key.
+// Every key created by NewKey is unique, even if created with the same name,
+// so keys from different packages never collide.
+type Key[T any] struct {
+	name string
+}
+
+// NewKey creates a key for attributes of type T. The name is only used for debugging.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+func (k *Key[T]) String() string {
+	return k.name
+}
+
+// Get gets the attribute from the context, returning false if it is not set.
+func (k *Key[T]) Get(c IContext) (T, bool) {
+	v, ok := c.GetAttribute(k).(T)
+	return v, ok
+}
+
+// Value gets the attribute from the context or the zero value of T if not set.
+func (k *Key[T]) Value(c IContext) T {
+	v, _ := k.Get(c)
+	return v
+}
+
+// Set sets the attribute in the context
+func (k *Key[T]) Set(c IContext, value T) {
+	c.SetAttribute(k, value)
+}
+
+// From gets the attribute from the context.Context of a request handled by Maze,
+// for code that only has access to the *http.Request.
+func (k *Key[T]) From(ctx context.Context) (T, bool) {
+	v, ok := AttributeFrom(ctx, k).(T)
+	return v, ok
+}
+
+// AttributeFrom gets an attribute from the context.Context of a request handled by Maze
+func AttributeFrom(ctx context.Context, key interface{}) interface{} {
+	if attrs, ok := ctx.Value(attributesKey{}).(map[interface{}]interface{}); ok {
+		return attrs[key]
+	}
+	return nil
+}
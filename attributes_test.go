@@ -0,0 +1,39 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey(t *testing.T) {
+	user := NewKey[string]("user")
+	other := NewKey[string]("user")
+	count := NewKey[int]("count")
+
+	m := NewMaze()
+	m.GET("/*", func(c IContext) error {
+		user.Set(c, "paulo")
+		count.Set(c, 3)
+		return c.Proceed()
+	}, func(c IContext) error {
+		v, ok := user.Get(c)
+		require.True(t, ok)
+		require.Equal(t, "paulo", v)
+		_, ok = other.Get(c)
+		require.False(t, ok)
+		require.Equal(t, 3, count.Value(c))
+
+		// plain library code only has the request
+		v, ok = user.From(c.GetRequest().Context())
+		require.True(t, ok)
+		require.Equal(t, "paulo", v)
+		return c.TEXT(http.StatusOK, v)
+	})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, "paulo", w.Body.String())
+}
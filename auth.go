@@ -0,0 +1,190 @@
+package maze
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+
+	"github.com/quintans/toolkit/web"
+)
+
+const (
+	UNAUTHORIZED = "MAZE10"
+
+	DefaultBasicRealm   = "Restricted"
+	DefaultAPIKeyHeader = "X-Api-Key"
+
+	// authentication methods
+	AuthBasic  = "basic"
+	AuthAPIKey = "apikey"
+)
+
+var principalKey = NewKey[*Principal]("principal")
+
+// Principal is the authenticated identity of a request
+type Principal struct {
+	// Name identifies the principal, eg: the user name
+	Name string
+	// Method is how the principal was authenticated, eg: AuthBasic
+	Method string
+	Roles  []string
+	Scopes []string
+	// Attributes holds any other information about the principal
+	Attributes map[string]interface{}
+}
+
+// ErrUnauthorized is returned when the request has no valid credentials
+var ErrUnauthorized = web.NewHttpFail(http.StatusUnauthorized, UNAUTHORIZED, "Unauthorized")
+
+// BasicVerifier checks the credentials, returning the principal or nil if they are invalid
+type BasicVerifier func(c IContext, username, password string) (*Principal, error)
+
+// APIKeyVerifier checks the API key, returning the principal or nil if it is invalid
+type APIKeyVerifier func(c IContext, key string) (*Principal, error)
+
+type BasicAuthConfig struct {
+	// Realm is sent in the challenge. Default is DefaultBasicRealm.
+	Realm    string
+	Verifier BasicVerifier
+}
+
+// BasicAuth authenticates the request with HTTP Basic credentials, setting the principal in the context.
+// Requests without valid credentials are rejected with 401 and a WWW-Authenticate challenge.
+func BasicAuth(cfg BasicAuthConfig) Handler {
+	if cfg.Verifier == nil {
+		panic("basic auth requires a Verifier")
+	}
+	realm := cfg.Realm
+	if realm == "" {
+		realm = DefaultBasicRealm
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+
+	return func(c IContext) error {
+		authChallengeKey.Set(c, challenge)
+		username, password, ok := c.GetRequest().BasicAuth()
+		if ok {
+			p, err := cfg.Verifier(c, username, password)
+			if err != nil {
+				return err
+			}
+			if p != nil {
+				if p.Method == "" {
+					p.Method = AuthBasic
+				}
+				c.SetPrincipal(p)
+				return c.Proceed()
+			}
+		}
+		c.GetResponse().Header().Set("WWW-Authenticate", challenge)
+		return ErrUnauthorized
+	}
+}
+
+// StaticCredentials verifies the credentials against a fixed map of user names and passwords,
+// in constant time so that it leaks neither the passwords nor the existing user names.
+func StaticCredentials(users map[string]string) BasicVerifier {
+	hashes := make(map[string][32]byte, len(users))
+	for u, p := range users {
+		hashes[u] = sha256.Sum256([]byte(p))
+	}
+	// compared against when the user does not exist, to take the same time
+	dummy := sha256.Sum256(nil)
+
+	return func(c IContext, username, password string) (*Principal, error) {
+		expected, ok := hashes[username]
+		if !ok {
+			expected = dummy
+		}
+		given := sha256.Sum256([]byte(password))
+		if subtle.ConstantTimeCompare(given[:], expected[:]) == 1 && ok {
+			return &Principal{Name: username}, nil
+		}
+		return nil, nil
+	}
+}
+
+type APIKeyConfig struct {
+	// Header is where the key is looked for first. Default is DefaultAPIKeyHeader, if neither Query nor Cookie are set.
+	Header string
+	// Query is the query parameter where the key is looked for, after the header
+	Query string
+	// Cookie is the cookie where the key is looked for, after the query parameter
+	Cookie   string
+	Verifier APIKeyVerifier
+}
+
+// APIKey authenticates the request with an API key, setting the principal in the context.
+// Requests without a valid key are rejected with 401.
+func APIKey(cfg APIKeyConfig) Handler {
+	if cfg.Verifier == nil {
+		panic("api key auth requires a Verifier")
+	}
+	if cfg.Header == "" && cfg.Query == "" && cfg.Cookie == "" {
+		cfg.Header = DefaultAPIKeyHeader
+	}
+
+	return func(c IContext) error {
+		key := apiKey(c.GetRequest(), cfg)
+		if key != "" {
+			p, err := cfg.Verifier(c, key)
+			if err != nil {
+				return err
+			}
+			if p != nil {
+				if p.Method == "" {
+					p.Method = AuthAPIKey
+				}
+				c.SetPrincipal(p)
+				return c.Proceed()
+			}
+		}
+		return ErrUnauthorized
+	}
+}
+
+func apiKey(r *http.Request, cfg APIKeyConfig) string {
+	if cfg.Header != "" {
+		if key := r.Header.Get(cfg.Header); key != "" {
+			return key
+		}
+	}
+	if cfg.Query != "" {
+		if key := r.URL.Query().Get(cfg.Query); key != "" {
+			return key
+		}
+	}
+	if cfg.Cookie != "" {
+		if cookie, err := r.Cookie(cfg.Cookie); err == nil {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// StaticAPIKeys verifies the key against a fixed map of keys and their principals.
+// Every key is compared, in constant time, so that the time taken does not leak which keys exist.
+// The principals are copied, so they can be shared.
+func StaticAPIKeys(keys map[string]Principal) APIKeyVerifier {
+	type entry struct {
+		hash      [32]byte
+		principal Principal
+	}
+	entries := make([]entry, 0, len(keys))
+	for k, p := range keys {
+		entries = append(entries, entry{hash: sha256.Sum256([]byte(k)), principal: p})
+	}
+
+	return func(c IContext, key string) (*Principal, error) {
+		given := sha256.Sum256([]byte(key))
+		var found *Principal
+		for k := range entries {
+			if subtle.ConstantTimeCompare(given[:], entries[k].hash[:]) == 1 {
+				p := entries[k].principal
+				found = &p
+			}
+		}
+		return found, nil
+	}
+}
@@ -0,0 +1,105 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicAuth(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", BasicAuth(BasicAuthConfig{
+		Realm:    "maze",
+		Verifier: StaticCredentials(map[string]string{"ana": "secret"}),
+	}))
+	m.GET("/me", func(c IContext) error {
+		p := c.Principal()
+		return c.TEXT(http.StatusOK, p.Name+" "+p.Method)
+	})
+
+	get := func(user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/me", nil)
+		if user != "" {
+			r.SetBasicAuth(user, password)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("ana", "secret")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "ana basic", w.Body.String())
+
+	for _, creds := range [][2]string{{"", ""}, {"ana", "wrong"}, {"rui", "secret"}} {
+		w = get(creds[0], creds[1])
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Equal(t, `Basic realm="maze", charset="UTF-8"`, w.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", APIKey(APIKeyConfig{
+		Header: "X-Key",
+		Query:  "key",
+		Cookie: "key",
+		Verifier: StaticAPIKeys(map[string]Principal{
+			"k1": {Name: "service-a", Roles: []string{"reader"}},
+		}),
+	}))
+	m.GET("/me", func(c IContext) error {
+		p := c.Principal()
+		return c.TEXT(http.StatusOK, p.Name+" "+p.Method+" "+p.Roles[0])
+	})
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	r := httptest.NewRequest("GET", "/me", nil)
+	r.Header.Set("X-Key", "k1")
+	w := serve(r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "service-a apikey reader", w.Body.String())
+
+	w = serve(httptest.NewRequest("GET", "/me?key=k1", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	r = httptest.NewRequest("GET", "/me", nil)
+	r.AddCookie(&http.Cookie{Name: "key", Value: "k1"})
+	w = serve(r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = serve(httptest.NewRequest("GET", "/me?key=k2", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	w = serve(httptest.NewRequest("GET", "/me", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestKeyByPrincipal(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", BasicAuth(BasicAuthConfig{
+		Verifier: StaticCredentials(map[string]string{"ana": "secret"}),
+	}))
+	m.Push("/*", RateLimit(RateLimitConfig{
+		RateLimitPolicy: RateLimitPolicy{Requests: 1, Window: time.Minute},
+		Key:             KeyByPrincipal(),
+	}))
+	m.GET("/me", func(c IContext) error {
+		return nil
+	})
+
+	for _, code := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		r := httptest.NewRequest("GET", "/me", nil)
+		r.SetBasicAuth("ana", "secret")
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		require.Equal(t, code, w.Code)
+	}
+}
@@ -0,0 +1,103 @@
+package maze
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/quintans/toolkit/web"
+)
+
+const FORBIDDEN = "MAZE11"
+
+// ErrForbidden is returned when the principal is not allowed to do the request
+var ErrForbidden = web.NewHttpFail(http.StatusForbidden, FORBIDDEN, "Forbidden")
+
+// HasRole checks if the principal has the role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope checks if the principal was granted the scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+var authChallengeKey = NewKey[string]("auth.challenge")
+
+// AuthChallenge sets the WWW-Authenticate challenge sent by Authorize to anonymous requests,
+// eg: mz.Push("/api/*", maze.AuthChallenge(`Bearer realm="api"`)).
+// BasicAuth and JWT set their own challenge, and the default is a Bearer challenge with DefaultBearerRealm.
+func AuthChallenge(challenge string) Handler {
+	return func(c IContext) error {
+		authChallengeKey.Set(c, challenge)
+		return c.Proceed()
+	}
+}
+
+// Policy decides if the authenticated principal is allowed to do the request
+type Policy func(c IContext, p *Principal) (bool, error)
+
+// Authorize proceeds only if the policy allows the principal set by an authentication filter.
+// Anonymous requests are rejected with 401, and the challenge set with AuthChallenge, and principals not allowed with 403.
+func Authorize(policy Policy) Handler {
+	defaultChallenge := "Bearer realm=" + strconv.Quote(DefaultBearerRealm)
+	return func(c IContext) error {
+		p := c.Principal()
+		if p == nil {
+			challenge, ok := authChallengeKey.Get(c)
+			if !ok {
+				challenge = defaultChallenge
+			}
+			c.GetResponse().Header().Set("WWW-Authenticate", challenge)
+			return ErrUnauthorized
+		}
+		ok, err := policy(c, p)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrForbidden
+		}
+		return c.Proceed()
+	}
+}
+
+// RequireRoles allows principals with any of the roles
+func RequireRoles(roles ...string) Handler {
+	return Authorize(func(c IContext, p *Principal) (bool, error) {
+		for _, r := range roles {
+			if p.HasRole(r) {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
+
+// RequireScopes allows principals with all the scopes.
+// Bearer token principals without them also get an insufficient_scope challenge.
+func RequireScopes(scopes ...string) Handler {
+	challenge := `Bearer error="insufficient_scope", scope=` + strconv.Quote(strings.Join(scopes, " "))
+	return Authorize(func(c IContext, p *Principal) (bool, error) {
+		for _, s := range scopes {
+			if !p.HasScope(s) {
+				if p.Method == AuthBearer {
+					c.GetResponse().Header().Set("WWW-Authenticate", challenge)
+				}
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
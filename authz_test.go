@@ -0,0 +1,88 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorization(t *testing.T) {
+	secret := []byte("secret")
+	m := NewMaze()
+	// authentication is optional for these routes
+	m.Push("/*", func(c IContext) error {
+		if c.GetRequest().Header.Get("Authorization") == "" {
+			return c.Proceed()
+		}
+		return JWT(JWTConfig{Keys: StaticKeys{"": secret}})(c)
+	})
+	m.GET("/admin", RequireRoles("admin", "root"), func(c IContext) error {
+		return c.TEXT(http.StatusOK, "admin")
+	})
+	m.GET("/reports", RequireScopes("reports:read", "reports:list"), func(c IContext) error {
+		return c.TEXT(http.StatusOK, "reports")
+	})
+	m.GET("/own/:Name", Authorize(func(c IContext, p *Principal) (bool, error) {
+		return c.PathValues().AsString("Name") == p.Name, nil
+	}), func(c IContext) error {
+		return c.TEXT(http.StatusOK, "own")
+	})
+	rpc, err := NewJsonRpcService(&calcService{})
+	require.NoError(t, err)
+	rpc.SetActionFilters("Double", func(c IContext) error {
+		c.GetResponse().Header().Set("X-Action", "filtered")
+		return c.Proceed()
+	})
+	rpc.RequireAction("Double", RequireRoles("calc"))
+	m.Add(rpc.Build("/calc")...)
+
+	do := func(method, path string, claims map[string]interface{}) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader("2"))
+		if claims != nil {
+			r.Header.Set("Authorization", "Bearer "+signJWT(t, "HS256", "", secret, claims))
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("GET", "/admin", nil)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, `Bearer realm="api"`, w.Header().Get("WWW-Authenticate"))
+	require.Equal(t, http.StatusForbidden, do("GET", "/admin", map[string]interface{}{"sub": "ana"}).Code)
+	require.Equal(t, http.StatusOK, do("GET", "/admin", map[string]interface{}{"sub": "ana", "roles": []string{"root"}}).Code)
+
+	w = do("GET", "/reports", map[string]interface{}{"sub": "ana", "scope": "reports:read"})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Equal(t, `Bearer error="insufficient_scope", scope="reports:read reports:list"`, w.Header().Get("WWW-Authenticate"))
+	require.Equal(t, http.StatusOK, do("GET", "/reports", map[string]interface{}{"sub": "ana", "scope": "reports:list reports:read"}).Code)
+
+	require.Equal(t, http.StatusOK, do("GET", "/own/ana", map[string]interface{}{"sub": "ana"}).Code)
+	require.Equal(t, http.StatusForbidden, do("GET", "/own/rui", map[string]interface{}{"sub": "ana"}).Code)
+
+	require.Equal(t, http.StatusUnauthorized, do("POST", "/calc/Double", nil).Code)
+	w = do("POST", "/calc/Double", map[string]interface{}{"sub": "ana"})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	// the guard runs before the action filters
+	require.Empty(t, w.Header().Get("X-Action"))
+	w = do("POST", "/calc/Double", map[string]interface{}{"sub": "ana", "roles": "calc"})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "4", w.Body.String())
+	require.Equal(t, "filtered", w.Header().Get("X-Action"))
+}
+
+func TestAuthChallenge(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", AuthChallenge(`Basic realm="reports"`))
+	m.GET("/reports", RequireRoles("admin"), func(c IContext) error {
+		return c.TEXT(http.StatusOK, "reports")
+	})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/reports", nil))
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, `Basic realm="reports"`, w.Header().Get("WWW-Authenticate"))
+}
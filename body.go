@@ -0,0 +1,169 @@
+package maze
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/quintans/toolkit/web"
+)
+
+const (
+	BODY_TOO_LARGE = "MAZE01"
+
+	// DefaultBodyLimit is the maximum size of a request body, unless changed with WithBodyLimit or BodyLimit
+	DefaultBodyLimit int64 = 10 << 20
+)
+
+// ErrBodyTooLarge is returned when reading a request body bigger than the limit.
+// When returned by a filter, the request ends with the status 413.
+var ErrBodyTooLarge = web.NewHttpFail(http.StatusRequestEntityTooLarge, BODY_TOO_LARGE, "request body too large")
+
+// BodyLimit overrides, for the requests matching the rule, the maximum size of the request body.
+// A limit of zero or less means no limit.
+func BodyLimit(limit int64) Handler {
+	return func(c IContext) error {
+		c.SetBodyLimit(limit)
+		return c.Proceed()
+	}
+}
+
+// limitedBody fails reading after the limit is reached.
+// The limit is read at every call so that it can be changed by later filters.
+type limitedBody struct {
+	io.ReadCloser
+	contentLength int64
+	limit         *int64
+	read          int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	limit := *b.limit
+	if limit <= 0 {
+		return b.ReadCloser.Read(p)
+	}
+	if b.read > limit || b.contentLength > limit {
+		return 0, ErrBodyTooLarge
+	}
+	// reads one byte more than allowed to detect the overflow
+	if max := limit - b.read + 1; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.read > limit {
+		return n - int(b.read-limit), ErrBodyTooLarge
+	}
+	return n, err
+}
+
+type payloadConfig struct {
+	disallowUnknownFields bool
+	disallowTrailingData  bool
+}
+
+// PayloadOption changes how the payload is decoded
+type PayloadOption func(*payloadConfig)
+
+// DisallowUnknownFields makes decoding fail if the payload has fields not present in the destination
+func DisallowUnknownFields() PayloadOption {
+	return func(c *payloadConfig) {
+		c.disallowUnknownFields = true
+	}
+}
+
+// DisallowTrailingData makes decoding fail if there is anything besides white space after the json value
+func DisallowTrailingData() PayloadOption {
+	return func(c *payloadConfig) {
+		c.disallowTrailingData = true
+	}
+}
+
+// decodeJSON decodes the json value read from r.
+// An empty body decodes nothing.
+func decodeJSON(r io.Reader, value interface{}, options ...PayloadOption) error {
+	var cfg payloadConfig
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	dec := json.NewDecoder(r)
+	if cfg.disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(value); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	if cfg.disallowTrailingData {
+		if _, err := dec.Token(); err != io.EOF {
+			if err == nil {
+				err = errors.New("unexpected data after the json value")
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultBodyMemoryThreshold is the size above which a cached request body is moved to a temporary file
+const DefaultBodyMemoryThreshold int64 = 1 << 20
+
+// bodyCache holds a request body so that it can be read more than once.
+// Bodies bigger than the threshold are kept in a temporary file.
+type bodyCache struct {
+	mem  []byte
+	file *os.File
+	size int64
+}
+
+func newBodyCache(r io.Reader, threshold int64) (*bodyCache, error) {
+	var buf bytes.Buffer
+	_, err := io.CopyN(&buf, r, threshold+1)
+	if err == io.EOF {
+		return &bodyCache{mem: buf.Bytes(), size: int64(buf.Len())}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ioutil.TempFile("", "maze-body")
+	if err != nil {
+		return nil, err
+	}
+	b := &bodyCache{file: f}
+	n, err := io.Copy(f, io.MultiReader(&buf, r))
+	if err != nil {
+		b.close()
+		return nil, err
+	}
+	b.size = n
+	return b, nil
+}
+
+func (b *bodyCache) reader() io.ReadCloser {
+	if b.file != nil {
+		return ioutil.NopCloser(io.NewSectionReader(b.file, 0, b.size))
+	}
+	return ioutil.NopCloser(bytes.NewReader(b.mem))
+}
+
+func (b *bodyCache) bytes() ([]byte, error) {
+	if b.file != nil {
+		return ioutil.ReadAll(b.reader())
+	}
+	return b.mem, nil
+}
+
+func (b *bodyCache) close() {
+	if b.file != nil {
+		b.file.Close()
+		os.Remove(b.file.Name())
+	}
+}
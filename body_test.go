@@ -0,0 +1,88 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyLimit(t *testing.T) {
+	m := NewMaze(WithBodyLimit(10))
+	m.POST("/big/*", BodyLimit(100))
+	m.POST("/*", func(c IContext) error {
+		var v map[string]string
+		if err := c.Payload(&v); err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, v)
+	})
+
+	body := `{"name": "Paulo Quintans"}`
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("POST", "/small", strings.NewReader(body)))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/small", strings.NewReader(body))
+	// unknown length forces reading up to the limit
+	r.ContentLength = -1
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("POST", "/big/one", strings.NewReader(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, `{"name":"Paulo Quintans"}`, w.Body.String())
+}
+
+func TestPayloadOptions(t *testing.T) {
+	m := NewMaze()
+	var v struct {
+		Name string `json:"name"`
+	}
+	decode := func(body string, options ...PayloadOption) error {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		return NewContextWithMaze(m, httptest.NewRecorder(), r, nil).Payload(&v, options...)
+	}
+
+	require.NoError(t, decode(``))
+	require.NoError(t, decode(`{"name": "a", "age": 1} {}`))
+	require.Error(t, decode(`{"name": "a", "age": 1}`, DisallowUnknownFields()))
+	require.Error(t, decode(`{"name": "a"} {}`, DisallowTrailingData()))
+	require.NoError(t, decode(`{"name": "a"}  `, DisallowTrailingData(), DisallowUnknownFields()))
+}
+
+func TestBodyCache(t *testing.T) {
+	for _, threshold := range []int64{1 << 10, 4} {
+		m := NewMaze(WithBodyMemoryThreshold(threshold))
+		var file string
+		m.POST("/*", func(c IContext) error {
+			b, err := c.Body()
+			require.NoError(t, err)
+			require.Equal(t, `{"name":"maze"}`, string(b))
+			if mc := c.(*MazeContext); mc.bodyCache.file != nil {
+				file = mc.bodyCache.file.Name()
+			}
+			return c.Proceed()
+		}, func(c IContext) error {
+			var v map[string]string
+			if err := c.Payload(&v); err != nil {
+				return err
+			}
+			b, err := c.Body()
+			require.NoError(t, err)
+			return c.TEXT(http.StatusOK, v["name"]+string(b))
+		})
+
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"maze"}`)))
+		require.Equal(t, `maze{"name":"maze"}`, w.Body.String())
+		if threshold < 10 {
+			require.NotEmpty(t, file)
+			require.NoFileExists(t, file)
+		}
+	}
+}
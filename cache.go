@@ -0,0 +1,419 @@
+package maze
+
+import (
+	"container/list"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quintans/toolkit/web"
+)
+
+const DefaultCacheTTL = time.Minute
+
+// DefaultCacheMaxEntries is the number of responses kept by the LRU store created by NewCache
+const DefaultCacheMaxEntries = 1000
+
+var cacheTagsKey = NewKey[[]string]("cache.tags")
+
+// CachedResponse is a complete response kept by a CacheStore
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// Vary, if not empty, means that this entry is only a marker of the request headers
+	// that select the response variant, stored under another key
+	Vary    []string
+	Tags    []string
+	Created time.Time
+	Expires time.Time
+}
+
+// CacheStore stores the cached responses.
+// Implementations must be safe for concurrent use.
+type CacheStore interface {
+	// Get returns the response stored with the key, or nil if there is none or if it expired
+	Get(key string) (*CachedResponse, error)
+	// Set stores the response with the key, replacing any previous one
+	Set(key string, res *CachedResponse) error
+	// Delete removes the response stored with the key
+	Delete(key string) error
+	// InvalidateTags removes every response tagged with any of the tags
+	InvalidateTags(tags ...string) error
+}
+
+type CacheConfig struct {
+	// Store is where the responses are kept. Default is an LRUStore with DefaultCacheMaxEntries.
+	Store CacheStore
+	// TTL is how long a response is kept, if the handler doesn't set Cache-Control max-age. Default is DefaultCacheTTL.
+	TTL time.Duration
+	// QueryParams are the query parameters that are part of the cache key.
+	// If nil, all query parameters are used.
+	QueryParams []string
+	// Tags are added to every response cached by this filter, for invalidation
+	Tags []string
+}
+
+// Cache is a filter that caches complete GET responses, keyed by path, query parameters and the request headers
+// listed in the Vary response header.
+// The client can bypass the cache with Cache-Control no-cache or no-store
+// and the handler can prevent caching with Cache-Control no-store or private,
+// or set the time to live with max-age, s-maxage or Expires. An invalid Expires, like -1, is ignored.
+// Only responses with status 200 and without cookies are cached.
+// Responses to requests with Authorization or with a principal are only stored and served
+// if they are marked as shared with Cache-Control public or s-maxage, as in RFC 9111 section 3.5,
+// so this filter should come after the authentication filters.
+// Responses are buffered, so it is not suited for streaming.
+type Cache struct {
+	store       CacheStore
+	ttl         time.Duration
+	queryParams []string
+	tags        []string
+}
+
+func NewCache(cfg CacheConfig) *Cache {
+	c := &Cache{
+		store:       cfg.Store,
+		ttl:         cfg.TTL,
+		queryParams: cfg.QueryParams,
+		tags:        cfg.Tags,
+	}
+	if c.store == nil {
+		c.store = NewLRUStore(DefaultCacheMaxEntries)
+	}
+	if c.ttl <= 0 {
+		c.ttl = DefaultCacheTTL
+	}
+	return c
+}
+
+// SetCacheTags adds tags to the response being cached, so that it can be invalidated with Cache.Invalidate
+func SetCacheTags(c IContext, tags ...string) {
+	cacheTagsKey.Set(c, append(cacheTagsKey.Value(c), tags...))
+}
+
+// Invalidate removes every cached response tagged with any of the tags
+func (c *Cache) Invalidate(tags ...string) error {
+	return c.store.InvalidateTags(tags...)
+}
+
+// Handle serves the response from the cache or caches the response of the next filters
+func (c *Cache) Handle(ctx IContext) error {
+	r := ctx.GetRequest()
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return ctx.Proceed()
+	}
+	reqCC := parseCacheControl(r.Header.Get("Cache-Control"))
+	if _, ok := reqCC["no-store"]; ok {
+		return ctx.Proceed()
+	}
+
+	key := c.key(r)
+	authenticated := r.Header.Get("Authorization") != "" || ctx.Principal() != nil
+	_, noCache := reqCC["no-cache"]
+	if maxAge, ok := reqCC["max-age"]; ok && maxAge == "0" {
+		noCache = true
+	}
+	if !noCache {
+		res, err := c.lookup(key, r)
+		if err != nil {
+			ctx.Logger().Warnf("unable to read from the cache: %+v", err)
+		} else if res != nil && (!authenticated || shared(parseCacheControl(res.Header.Get("Cache-Control")))) {
+			return writeCached(ctx.GetResponse(), res)
+		}
+	}
+
+	if r.Method == http.MethodHead {
+		// a HEAD response has no body to cache
+		return ctx.Proceed()
+	}
+
+	rec := web.NewBufferedResponse()
+	w := ctx.GetResponse()
+	ctx.SetResponse(rec)
+	// restores the original response, even in the case of a panic
+	defer ctx.SetResponse(w)
+	err := ctx.Proceed()
+	if err != nil {
+		return err
+	}
+
+	res := c.cacheable(ctx, rec, authenticated)
+	if res != nil {
+		if err := c.save(key, r, res); err != nil {
+			ctx.Logger().Warnf("unable to write to the cache: %+v", err)
+		}
+	}
+
+	for k, v := range rec.Header() {
+		w.Header()[k] = v
+	}
+	if res != nil {
+		w.Header().Set("X-Cache", "MISS")
+	}
+	if rec.Code != 0 {
+		w.WriteHeader(rec.Code)
+	}
+	_, err = w.Write(rec.Body.Bytes())
+	return err
+}
+
+func (c *Cache) lookup(key string, r *http.Request) (*CachedResponse, error) {
+	res, err := c.store.Get(key)
+	if err != nil || res == nil || len(res.Vary) == 0 {
+		return res, err
+	}
+	return c.store.Get(variantKey(key, res.Vary, r))
+}
+
+func (c *Cache) save(key string, r *http.Request, res *CachedResponse) error {
+	if len(res.Vary) == 0 {
+		return c.store.Set(key, res)
+	}
+	marker := &CachedResponse{
+		Vary:    res.Vary,
+		Tags:    res.Tags,
+		Created: res.Created,
+		Expires: res.Expires,
+	}
+	if err := c.store.Set(key, marker); err != nil {
+		return err
+	}
+	variant := *res
+	variant.Vary = nil
+	return c.store.Set(variantKey(key, res.Vary, r), &variant)
+}
+
+// cacheable returns the response to cache or nil if it cannot be cached
+func (c *Cache) cacheable(ctx IContext, rec *web.BufferedResponse, authenticated bool) *CachedResponse {
+	h := rec.Header()
+	if (rec.Code != 0 && rec.Code != http.StatusOK) || h.Get("Set-Cookie") != "" {
+		return nil
+	}
+	resCC := parseCacheControl(h.Get("Cache-Control"))
+	for _, d := range []string{"no-store", "private", "no-cache"} {
+		if _, ok := resCC[d]; ok {
+			return nil
+		}
+	}
+	if authenticated && !shared(resCC) {
+		return nil
+	}
+	ttl := c.ttl
+	maxAge, ok := resCC["s-maxage"]
+	if !ok {
+		maxAge, ok = resCC["max-age"]
+	}
+	if ok {
+		secs, err := strconv.Atoi(maxAge)
+		if err != nil || secs <= 0 {
+			return nil
+		}
+		ttl = time.Duration(secs) * time.Second
+	} else if expires, err := http.ParseTime(h.Get("Expires")); err == nil {
+		// max-age takes precedence over Expires.
+		// An invalid Expires, like the -1 of TEXT and JSON, is ignored.
+		if !expires.After(time.Now()) {
+			return nil
+		}
+		ttl = time.Until(expires)
+	}
+
+	var vary []string
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return nil
+			}
+			if name != "" {
+				vary = append(vary, name)
+			}
+		}
+	}
+	sort.Strings(vary)
+
+	now := time.Now()
+	return &CachedResponse{
+		Status:  http.StatusOK,
+		Header:  h.Clone(),
+		Body:    append([]byte(nil), rec.Body.Bytes()...),
+		Vary:    vary,
+		Tags:    append(append([]string(nil), c.tags...), cacheTagsKey.Value(ctx)...),
+		Created: now,
+		Expires: now.Add(ttl),
+	}
+}
+
+// shared tells if a response to an authenticated request can be cached and served to other requests
+func shared(resCC map[string]string) bool {
+	_, public := resCC["public"]
+	_, sMaxAge := resCC["s-maxage"]
+	return public || sMaxAge
+}
+
+// key identifies the resource by path and the selected query parameters.
+// HEAD requests share the key of GET.
+func (c *Cache) key(r *http.Request) string {
+	query := r.URL.Query()
+	if c.queryParams != nil {
+		selected := url.Values{}
+		for _, p := range c.queryParams {
+			if v, ok := query[p]; ok {
+				selected[p] = v
+			}
+		}
+		query = selected
+	}
+	return http.MethodGet + " " + r.URL.Path + "?" + query.Encode()
+}
+
+func variantKey(key string, vary []string, r *http.Request) string {
+	var sb strings.Builder
+	sb.WriteString(key)
+	for _, name := range vary {
+		sb.WriteString("\n")
+		sb.WriteString(name)
+		sb.WriteString(": ")
+		sb.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	return sb.String()
+}
+
+func writeCached(w http.ResponseWriter, res *CachedResponse) error {
+	h := w.Header()
+	for k, v := range res.Header {
+		h[k] = append([]string(nil), v...)
+	}
+	h.Set("Age", strconv.Itoa(int(time.Since(res.Created).Seconds())))
+	h.Set("X-Cache", "HIT")
+	w.WriteHeader(res.Status)
+	_, err := w.Write(res.Body)
+	return err
+}
+
+// parseCacheControl returns the directives, in lower case, and their values
+func parseCacheControl(header string) map[string]string {
+	directives := map[string]string{}
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return directives
+}
+
+type lruEntry struct {
+	key string
+	res *CachedResponse
+}
+
+// LRUStore is an in-memory CacheStore that evicts the least recently used responses
+// when the maximum number of entries is reached
+type LRUStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	entries    map[string]*list.Element
+	tags       map[string]map[string]struct{}
+}
+
+var _ CacheStore = &LRUStore{}
+
+// NewLRUStore creates a store holding at most maxEntries responses. Zero or less means no limit.
+func NewLRUStore(maxEntries int) *LRUStore {
+	return &LRUStore{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    map[string]*list.Element{},
+		tags:       map[string]map[string]struct{}{},
+	}
+}
+
+func (s *LRUStore) Get(key string) (*CachedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := e.Value.(*lruEntry)
+	if time.Now().After(entry.res.Expires) {
+		s.remove(e)
+		return nil, nil
+	}
+	s.order.MoveToFront(e)
+	return entry.res, nil
+}
+
+func (s *LRUStore) Set(key string, res *CachedResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		s.remove(e)
+	}
+	s.entries[key] = s.order.PushFront(&lruEntry{key: key, res: res})
+	for _, tag := range res.Tags {
+		keys := s.tags[tag]
+		if keys == nil {
+			keys = map[string]struct{}{}
+			s.tags[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+func (s *LRUStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		s.remove(e)
+	}
+	return nil
+}
+
+func (s *LRUStore) InvalidateTags(tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			if e, ok := s.entries[key]; ok {
+				s.remove(e)
+			}
+		}
+		delete(s.tags, tag)
+	}
+	return nil
+}
+
+// Len returns the number of stored entries
+func (s *LRUStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *LRUStore) remove(e *list.Element) {
+	entry := e.Value.(*lruEntry)
+	s.order.Remove(e)
+	delete(s.entries, entry.key)
+	for _, tag := range entry.res.Tags {
+		if keys, ok := s.tags[tag]; ok {
+			delete(keys, entry.key)
+			if len(keys) == 0 {
+				delete(s.tags, tag)
+			}
+		}
+	}
+}
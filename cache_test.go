@@ -0,0 +1,174 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache(t *testing.T) {
+	calls := 0
+	cache := NewCache(CacheConfig{QueryParams: []string{"page"}})
+	m := NewMaze()
+	m.GET("/items", cache.Handle, func(c IContext) error {
+		calls++
+		SetCacheTags(c, "items")
+		return c.TEXT(http.StatusOK, calls)
+	})
+	m.GET("/private", cache.Handle, func(c IContext) error {
+		calls++
+		c.GetResponse().Header().Set("Cache-Control", "private")
+		return c.TEXT(http.StatusOK, calls)
+	})
+	m.GET("/lang", cache.Handle, func(c IContext) error {
+		calls++
+		c.GetResponse().Header().Set("Vary", "Accept-Language")
+		return c.TEXT(http.StatusOK, c.GetRequest().Header.Get("Accept-Language"))
+	})
+
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w
+	}
+
+	w := get("/items?page=1&ignored=a")
+	require.Equal(t, "1", w.Body.String())
+	require.Equal(t, "MISS", w.Header().Get("X-Cache"))
+	w = get("/items?ignored=b&page=1")
+	require.Equal(t, "1", w.Body.String())
+	require.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	require.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, "2", get("/items?page=2").Body.String())
+
+	// the client asks for a fresh response
+	require.Equal(t, "3", get("/items?page=1", "Cache-Control", "no-cache").Body.String())
+	require.Equal(t, "3", get("/items?page=1").Body.String())
+
+	require.NoError(t, cache.Invalidate("items"))
+	require.Equal(t, "4", get("/items?page=1").Body.String())
+
+	require.Equal(t, "5", get("/private").Body.String())
+	require.Equal(t, "6", get("/private").Body.String())
+
+	require.Equal(t, "en", get("/lang", "Accept-Language", "en").Body.String())
+	require.Equal(t, "pt", get("/lang", "Accept-Language", "pt").Body.String())
+	w = get("/lang", "Accept-Language", "en")
+	require.Equal(t, "en", w.Body.String())
+	require.Equal(t, "HIT", w.Header().Get("X-Cache"))
+	require.Equal(t, 8, calls)
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	calls := 0
+	m := NewMaze()
+	m.GET("/clock", NewCache(CacheConfig{}).Handle, func(c IContext) error {
+		calls++
+		c.GetResponse().Header().Set("Cache-Control", "max-age=0")
+		return c.TEXT(http.StatusOK, calls)
+	})
+	for i := 1; i <= 2; i++ {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest("GET", "/clock", nil))
+		require.Equal(t, strconv.Itoa(i), w.Body.String())
+	}
+}
+
+func TestCacheExpires(t *testing.T) {
+	calls := 0
+	m := NewMaze()
+	cache := NewCache(CacheConfig{})
+	m.GET("/expired", cache.Handle, func(c IContext) error {
+		calls++
+		err := c.TEXT(http.StatusOK, calls)
+		c.GetResponse().Header().Set("Expires", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+		return err
+	})
+	m.GET("/fresh", cache.Handle, func(c IContext) error {
+		calls++
+		err := c.TEXT(http.StatusOK, calls)
+		c.GetResponse().Header().Set("Expires", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+		return err
+	})
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+	w := get("/expired")
+	require.Equal(t, "1", w.Body.String())
+	require.Empty(t, w.Header().Get("X-Cache"))
+	require.Equal(t, "2", get("/expired").Body.String())
+	require.Equal(t, "3", get("/fresh").Body.String())
+	require.Equal(t, "3", get("/fresh").Body.String())
+}
+
+func TestCacheAuthenticated(t *testing.T) {
+	m := NewMaze()
+	cache := NewCache(CacheConfig{})
+	auth := BasicAuth(BasicAuthConfig{Verifier: StaticCredentials(map[string]string{
+		"alice": "a",
+		"bob":   "b",
+	})})
+	m.GET("/me", auth, cache.Handle, func(c IContext) error {
+		c.GetResponse().Header().Set("Cache-Control", "max-age=60")
+		return c.TEXT(http.StatusOK, "secret of "+c.Principal().Name)
+	})
+	m.GET("/news", auth, cache.Handle, func(c IContext) error {
+		c.GetResponse().Header().Set("Cache-Control", "public, max-age=60")
+		return c.TEXT(http.StatusOK, "news for "+c.Principal().Name)
+	})
+	get := func(path, user, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.SetBasicAuth(user, password)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		return w
+	}
+
+	get("/me", "alice", "a")
+	w := get("/me", "bob", "b")
+	require.Equal(t, "secret of bob", w.Body.String())
+	require.Empty(t, w.Header().Get("X-Cache"))
+
+	// the handler marked the response as shared
+	get("/news", "alice", "a")
+	w = get("/news", "bob", "b")
+	require.Equal(t, "news for alice", w.Body.String())
+	require.Equal(t, "HIT", w.Header().Get("X-Cache"))
+}
+
+func TestLRUStore(t *testing.T) {
+	s := NewLRUStore(2)
+	res := func(tags ...string) *CachedResponse {
+		return &CachedResponse{Status: http.StatusOK, Tags: tags, Expires: time.Now().Add(time.Minute)}
+	}
+	require.NoError(t, s.Set("a", res("x")))
+	require.NoError(t, s.Set("b", res("y")))
+	// a becomes the most recently used
+	r, err := s.Get("a")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	require.NoError(t, s.Set("c", res("x")))
+	require.Equal(t, 2, s.Len())
+	r, _ = s.Get("b")
+	require.Nil(t, r)
+
+	require.NoError(t, s.InvalidateTags("x"))
+	require.Equal(t, 0, s.Len())
+
+	require.NoError(t, s.Set("d", &CachedResponse{Expires: time.Now().Add(-time.Second)}))
+	r, _ = s.Get("d")
+	require.Nil(t, r)
+	require.Equal(t, 0, s.Len())
+}
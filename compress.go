@@ -0,0 +1,302 @@
+package maze
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	EncodingBrotli  = "br"
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+
+	// DefaultCompressMinSize is the minimum size of a compressed response
+	DefaultCompressMinSize = 1024
+)
+
+// DefaultCompressTypes are the media types compressed by default
+var DefaultCompressTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+type CompressConfig struct {
+	// MinSize is the minimum response size to be compressed. Default is DefaultCompressMinSize.
+	MinSize int
+	// ContentTypes are the compressed media types, eg: application/json or text/*. Default is DefaultCompressTypes.
+	// Server sent events are never compressed.
+	ContentTypes []string
+	// Encodings are the supported encodings, by order of preference. Default is br, gzip and deflate.
+	Encodings []string
+}
+
+// encoder is implemented by the gzip, zlib and brotli writers
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+type compressor struct {
+	minSize      int
+	contentTypes []string
+	encodings    []string
+	pools        map[string]*sync.Pool
+}
+
+// Compress compresses the responses, negotiating the encoding with the Accept-Encoding header.
+// Responses are only compressed if they are big enough and of an allowed content type.
+// Responses that are flushed before reaching the minimum size, like server sent events, are streamed uncompressed.
+func Compress(cfg CompressConfig) Handler {
+	c := &compressor{
+		minSize:      cfg.MinSize,
+		contentTypes: cfg.ContentTypes,
+		encodings:    cfg.Encodings,
+		pools: map[string]*sync.Pool{
+			EncodingBrotli: {New: func() interface{} {
+				return brotli.NewWriter(nil)
+			}},
+			EncodingGzip: {New: func() interface{} {
+				return gzip.NewWriter(nil)
+			}},
+			// HTTP deflate is the zlib format (RFC 9110 section 8.4.1.2), not raw deflate
+			EncodingDeflate: {New: func() interface{} {
+				return zlib.NewWriter(nil)
+			}},
+		},
+	}
+	if c.minSize <= 0 {
+		c.minSize = DefaultCompressMinSize
+	}
+	if len(c.contentTypes) == 0 {
+		c.contentTypes = DefaultCompressTypes
+	}
+	if len(c.encodings) == 0 {
+		c.encodings = []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+	}
+
+	return func(ctx IContext) error {
+		w := ctx.GetResponse()
+		r := ctx.GetRequest()
+		addVary(w.Header(), "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), c.encodings)
+		if encoding == "" || r.Method == http.MethodHead {
+			return ctx.Proceed()
+		}
+
+		cw := &compressWriter{
+			ResponseWriter: w,
+			compressor:     c,
+			encoding:       encoding,
+		}
+		ctx.SetResponse(cw)
+		// restores the original response, even in the case of a panic
+		defer ctx.SetResponse(w)
+
+		err := ctx.Proceed()
+		if cerr := cw.finish(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+}
+
+// negotiateEncoding returns the supported encoding, by order of preference, accepted by the client
+func negotiateEncoding(accept string, supported []string) string {
+	if accept == "" {
+		return ""
+	}
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				weight = f
+			}
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+	for _, s := range supported {
+		if w, ok := q[s]; ok {
+			if w > 0 {
+				return s
+			}
+			continue
+		}
+		if w, ok := q["*"]; ok && w > 0 {
+			return s
+		}
+	}
+	return ""
+}
+
+// addVary adds the value to the Vary header, if not there already
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p == "*" || strings.EqualFold(p, value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
+
+// compressWriter buffers the beginning of the response until it is possible to decide if it should be compressed
+type compressWriter struct {
+	http.ResponseWriter
+	*compressor
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool
+	enc      encoder
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.decided {
+		if w.enc == nil {
+			w.ResponseWriter.WriteHeader(code)
+		}
+		return
+	}
+	// informational responses are not the final status
+	if code >= 100 && code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide sends the status and the buffered content, compressed if allowed and compressible.
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if compress && w.compressible() {
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		w.enc = w.pools[w.encoding].Get().(encoder)
+		w.enc.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	switch w.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	mt, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || mt == "text/event-stream" {
+		return false
+	}
+	return allowedType(mt, w.contentTypes)
+}
+
+// Flush sends what was written so far. If it was not yet decided to compress, the response is streamed uncompressed.
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		// streamed responses are not compressed
+		w.decide(false)
+	}
+	if w.enc != nil {
+		w.enc.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("the response writer does not support hijacking")
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish sends what was buffered and closes the encoder
+func (w *compressWriter) finish() error {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			// nothing was written, eg: an error that will be handled by maze
+			return nil
+		}
+		// smaller than the minimum size
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.enc != nil {
+		err := w.enc.Close()
+		w.enc.Reset(nil)
+		w.pools[w.encoding].Put(w.enc)
+		w.enc = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package maze
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{EncodingBrotli, EncodingGzip, EncodingDeflate}
+	require.Equal(t, "", negotiateEncoding("", supported))
+	require.Equal(t, EncodingGzip, negotiateEncoding("gzip, deflate", supported))
+	require.Equal(t, EncodingBrotli, negotiateEncoding("gzip, br", supported))
+	require.Equal(t, EncodingDeflate, negotiateEncoding("br;q=0, gzip;q=0, *", supported))
+	require.Equal(t, "", negotiateEncoding("identity", supported))
+}
+
+func TestCompress(t *testing.T) {
+	big := strings.Repeat("maze ", 500)
+	m := NewMaze()
+	m.Push("/*", Compress(CompressConfig{}))
+	m.GET("/big", func(c IContext) error {
+		return c.JSON(http.StatusOK, big)
+	})
+	m.GET("/small", func(c IContext) error {
+		return c.JSON(http.StatusOK, "small")
+	})
+	m.GET("/image", func(c IContext) error {
+		c.GetResponse().Header().Set("Content-Type", "image/png")
+		_, err := c.GetResponse().Write([]byte(big))
+		return err
+	})
+	m.GET("/buffered", ResponseBuffer, func(c IContext) error {
+		return c.TEXT(http.StatusCreated, big)
+	})
+	broker := NewSseBroker()
+	broker.OnConnect = func() (Sse, error) {
+		return NewSse(big), nil
+	}
+	m.GET("/events", broker.Serve)
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Accept-Encoding", accept)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		return w
+	}
+
+	w := get("/big", "gzip")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, EncodingGzip, w.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, `"`+big+`"`, string(b))
+
+	w = get("/buffered", "br")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, EncodingBrotli, w.Header().Get("Content-Encoding"))
+	b, err = io.ReadAll(brotli.NewReader(w.Body))
+	require.NoError(t, err)
+	require.Equal(t, big, string(b))
+
+	w = get("/big", "deflate")
+	require.Equal(t, EncodingDeflate, w.Header().Get("Content-Encoding"))
+	dr, err := zlib.NewReader(w.Body)
+	require.NoError(t, err)
+	b, err = io.ReadAll(dr)
+	require.NoError(t, err)
+	require.Equal(t, `"`+big+`"`, string(b))
+
+	w = get("/small", "gzip")
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Equal(t, `"small"`, w.Body.String())
+
+	w = get("/image", "gzip")
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+
+	w = get("/big", "")
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+
+	// server sent events are streamed uncompressed
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for !broker.HasSubscribers() {
+			time.Sleep(time.Millisecond)
+		}
+		broker.Send(NewSse("bye"))
+		cancel()
+	}()
+	r := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.True(t, w.Flushed)
+	require.True(t, strings.HasPrefix(w.Body.String(), "data: maze"))
+}
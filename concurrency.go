@@ -0,0 +1,178 @@
+package maze
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/quintans/toolkit/web"
+)
+
+const OVERLOADED = "MAZE09"
+
+// DefaultAdaptiveBackoff is the factor applied to the adaptive limit when the latency is above the target
+const DefaultAdaptiveBackoff = 0.9
+
+type ConcurrencyConfig struct {
+	// Limit is the maximum number of requests being handled at the same time.
+	// With an adaptive limit it is the initial limit.
+	Limit int
+	// QueueSize is the maximum number of requests waiting for their turn.
+	// Requests beyond it are rejected with 503. Zero means that no request waits.
+	QueueSize int
+	// MaxWait is the maximum time a request waits in the queue before being rejected with 503.
+	// Zero means that it waits until the client gives up.
+	MaxWait time.Duration
+	// Adaptive, if set, adjusts the limit to the observed latency
+	Adaptive *AdaptiveLimit
+}
+
+// AdaptiveLimit adjusts the concurrency limit, increasing it by one when the limit is reached
+// with latencies below the target and decreasing it by the backoff factor when the latency is above the target.
+type AdaptiveLimit struct {
+	MinLimit int
+	MaxLimit int
+	// TargetLatency is the latency above which the limit decreases.
+	// The limit decreases at most once per TargetLatency, to give time for the effects to be seen.
+	TargetLatency time.Duration
+	// Backoff is the factor, between 0 and 1, applied to the limit when decreasing. Default is DefaultAdaptiveBackoff.
+	Backoff float64
+}
+
+// ConcurrencyLimiter caps the number of requests being handled at the same time, queueing the excess up to a bound.
+// A limiter pushed for /* caps the whole server and one pushed for a group of routes, eg: /reports/*, caps only that group.
+type ConcurrencyLimiter struct {
+	mu           sync.Mutex
+	limit        int
+	inFlight     int
+	queue        *list.List
+	queueSize    int
+	maxWait      time.Duration
+	adaptive     *AdaptiveLimit
+	lastDecrease time.Time
+}
+
+func NewConcurrencyLimiter(cfg ConcurrencyConfig) *ConcurrencyLimiter {
+	if cfg.Limit <= 0 {
+		panic("concurrency limiter requires a positive Limit")
+	}
+	l := &ConcurrencyLimiter{
+		limit:     cfg.Limit,
+		queue:     list.New(),
+		queueSize: cfg.QueueSize,
+		maxWait:   cfg.MaxWait,
+	}
+	if cfg.Adaptive != nil {
+		a := *cfg.Adaptive
+		if a.MinLimit <= 0 {
+			a.MinLimit = 1
+		}
+		if a.MaxLimit < cfg.Limit {
+			a.MaxLimit = cfg.Limit
+		}
+		if a.Backoff <= 0 || a.Backoff >= 1 {
+			a.Backoff = DefaultAdaptiveBackoff
+		}
+		l.adaptive = &a
+	}
+	return l
+}
+
+// Limit returns the current limit
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limit
+}
+
+// InFlight returns the number of requests being handled
+func (l *ConcurrencyLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inFlight
+}
+
+// Queued returns the number of requests waiting
+func (l *ConcurrencyLimiter) Queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.queue.Len()
+}
+
+// Handle proceeds when there is room for the request, or rejects it with 503
+func (l *ConcurrencyLimiter) Handle(c IContext) error {
+	if !l.acquire(c) {
+		return web.NewHttpFail(http.StatusServiceUnavailable, OVERLOADED, "Server overloaded")
+	}
+	start := time.Now()
+	defer func() {
+		l.release(time.Since(start))
+	}()
+	return c.Proceed()
+}
+
+func (l *ConcurrencyLimiter) acquire(c IContext) bool {
+	l.mu.Lock()
+	if l.inFlight < l.limit {
+		l.inFlight++
+		l.mu.Unlock()
+		return true
+	}
+	if l.queue.Len() >= l.queueSize {
+		l.mu.Unlock()
+		return false
+	}
+	// the slot is handed over by release, closing the channel
+	ready := make(chan struct{})
+	e := l.queue.PushBack(ready)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if l.maxWait > 0 {
+		timer := time.NewTimer(l.maxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ready:
+		return true
+	case <-timeout:
+	case <-c.GetRequest().Context().Done():
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-ready:
+		// the slot was handed over in the meantime
+		return true
+	default:
+		l.queue.Remove(e)
+		return false
+	}
+}
+
+func (l *ConcurrencyLimiter) release(latency time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if a := l.adaptive; a != nil {
+		if latency > a.TargetLatency {
+			if now := time.Now(); now.Sub(l.lastDecrease) >= a.TargetLatency {
+				l.lastDecrease = now
+				l.limit = int(math.Max(float64(a.MinLimit), math.Floor(float64(l.limit)*a.Backoff)))
+			}
+		} else if l.inFlight >= l.limit && l.limit < a.MaxLimit {
+			l.limit++
+		}
+	}
+
+	l.inFlight--
+	for l.inFlight < l.limit && l.queue.Len() > 0 {
+		ready := l.queue.Remove(l.queue.Front()).(chan struct{})
+		l.inFlight++
+		close(ready)
+	}
+}
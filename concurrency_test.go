@@ -0,0 +1,103 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyConfig{Limit: 1, QueueSize: 1})
+	release := make(chan struct{})
+	m := NewMaze()
+	m.Push("/*", limiter.Handle)
+	m.GET("/slow", func(c IContext) error {
+		<-release
+		return c.TEXT(http.StatusOK, "done")
+	})
+
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+		return w
+	}
+
+	var wg sync.WaitGroup
+	codes := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve().Code
+		}()
+	}
+	require.Eventually(t, func() bool {
+		return limiter.InFlight() == 1 && limiter.Queued() == 1
+	}, time.Second, time.Millisecond)
+
+	// the queue is full
+	w := serve()
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	close(release)
+	wg.Wait()
+	require.Equal(t, http.StatusOK, <-codes)
+	require.Equal(t, http.StatusOK, <-codes)
+	require.Equal(t, 0, limiter.InFlight())
+}
+
+func TestConcurrencyLimiterMaxWait(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyConfig{Limit: 1, QueueSize: 5, MaxWait: 20 * time.Millisecond})
+	release := make(chan struct{})
+	m := NewMaze()
+	m.GET("/slow", limiter.Handle, func(c IContext) error {
+		<-release
+		return nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil))
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return limiter.InFlight() == 1 }, time.Second, time.Millisecond)
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, 0, limiter.Queued())
+
+	close(release)
+	<-done
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	limiter := NewConcurrencyLimiter(ConcurrencyConfig{
+		Limit: 10,
+		Adaptive: &AdaptiveLimit{
+			MinLimit:      2,
+			MaxLimit:      20,
+			TargetLatency: 100 * time.Millisecond,
+		},
+	})
+
+	limiter.inFlight = 1
+	limiter.release(time.Second)
+	require.Equal(t, 9, limiter.Limit())
+	// decreases at most once per target latency
+	limiter.inFlight = 1
+	limiter.release(time.Second)
+	require.Equal(t, 9, limiter.Limit())
+
+	// increases when saturated with good latencies
+	limiter.inFlight = 9
+	limiter.release(time.Millisecond)
+	require.Equal(t, 10, limiter.Limit())
+	limiter.inFlight = 1
+	limiter.release(time.Millisecond)
+	require.Equal(t, 10, limiter.Limit())
+}
@@ -0,0 +1,255 @@
+package maze
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ConditionalConfig struct {
+	// Weak generates weak ETags, for responses that are semantically but not byte for byte equivalent
+	Weak bool
+	// Version, if set, returns the current ETag and/or last modification time of the resource,
+	// allowing to reply 304 or 412 without executing the next filters.
+	// An empty ETag and a zero time mean that the version is unknown.
+	Version func(c IContext) (etag string, lastModified time.Time, err error)
+}
+
+// Conditional handles conditional requests.
+// GET and HEAD requests get 304 (Not Modified) if the If-None-Match or If-Modified-Since headers match,
+// and other methods get 412 (Precondition Failed) if the If-Match or If-Unmodified-Since headers don't match.
+// If the version is not known beforehand, successful GET and HEAD responses are buffered to compute their ETag,
+// holding the whole response in memory, so large downloads should provide the Version.
+// Server-sent events and flushed responses are streamed as soon as they are detected, without an ETag.
+func Conditional(cfg ConditionalConfig) Handler {
+	return func(c IContext) error {
+		r := c.GetRequest()
+		if cfg.Version != nil {
+			etag, lastModified, err := cfg.Version(c)
+			if err != nil {
+				return err
+			}
+			if etag != "" || !lastModified.IsZero() {
+				if CheckPreconditions(c, etag, lastModified) {
+					return nil
+				}
+				return c.Proceed()
+			}
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead || acceptsEventStream(r) {
+			return c.Proceed()
+		}
+
+		w := c.GetResponse()
+		rec := &conditionalWriter{w: w, header: http.Header{}}
+		c.SetResponse(rec)
+		// restores the original response, even in the case of a panic
+		defer c.SetResponse(w)
+		err := c.Proceed()
+		if err != nil || rec.streaming {
+			return err
+		}
+
+		h := rec.header
+		if rec.Code == http.StatusOK && h.Get("ETag") == "" {
+			sum := sha256.Sum256(rec.Body.Bytes())
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			if cfg.Weak {
+				etag = "W/" + etag
+			}
+			h.Set("ETag", etag)
+		}
+		for k, v := range h {
+			w.Header()[k] = v
+		}
+		if rec.Code == http.StatusOK {
+			lastModified, _ := http.ParseTime(h.Get("Last-Modified"))
+			if status := evaluatePreconditions(r, h.Get("ETag"), lastModified); status != 0 {
+				writePreconditionStatus(w, status)
+				return nil
+			}
+		}
+		if rec.Code != 0 {
+			w.WriteHeader(rec.Code)
+		}
+		_, err = w.Write(rec.Body.Bytes())
+		return err
+	}
+}
+
+// conditionalWriter buffers the response, unless it turns out to be a stream
+type conditionalWriter struct {
+	w         http.ResponseWriter
+	header    http.Header
+	Code      int
+	Body      bytes.Buffer
+	streaming bool
+}
+
+func (cw *conditionalWriter) Header() http.Header {
+	return cw.header
+}
+
+func (cw *conditionalWriter) WriteHeader(code int) {
+	if cw.streaming {
+		cw.w.WriteHeader(code)
+		return
+	}
+	if cw.Code == 0 {
+		cw.Code = code
+	}
+	if isEventStream(cw.header) {
+		cw.stream()
+	}
+}
+
+func (cw *conditionalWriter) Write(b []byte) (int, error) {
+	if !cw.streaming {
+		if cw.Code == 0 {
+			cw.Code = http.StatusOK
+		}
+		if !isEventStream(cw.header) {
+			return cw.Body.Write(b)
+		}
+		cw.stream()
+	}
+	return cw.w.Write(b)
+}
+
+// Flush streams the response, since it is no longer possible to compute its ETag
+func (cw *conditionalWriter) Flush() {
+	if !cw.streaming {
+		cw.stream()
+	}
+	if f, ok := cw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stream sends what was buffered and writes through from now on
+func (cw *conditionalWriter) stream() {
+	cw.streaming = true
+	for k, v := range cw.header {
+		cw.w.Header()[k] = v
+	}
+	cw.header = cw.w.Header()
+	if cw.Code != 0 {
+		cw.w.WriteHeader(cw.Code)
+	}
+	if cw.Body.Len() > 0 {
+		cw.w.Write(cw.Body.Bytes())
+		cw.Body.Reset()
+	}
+}
+
+// CheckPreconditions sets the ETag and Last-Modified headers, if not empty,
+// and evaluates the request preconditions against them.
+// If the preconditions fail, it replies 304 or 412 and returns true, meaning that the request is done.
+// Handlers can use it to avoid building a response that the client already has.
+func CheckPreconditions(c IContext, etag string, lastModified time.Time) bool {
+	w := c.GetResponse()
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if status := evaluatePreconditions(c.GetRequest(), etag, lastModified); status != 0 {
+		writePreconditionStatus(w, status)
+		return true
+	}
+	return false
+}
+
+func writePreconditionStatus(w http.ResponseWriter, status int) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	if status == http.StatusNotModified {
+		w.WriteHeader(status)
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// evaluatePreconditions follows the order defined in RFC 7232, section 6,
+// returning 304, 412 or zero if the request should proceed.
+func evaluatePreconditions(r *http.Request, etag string, lastModified time.Time) int {
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !matchETag(im, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius := r.Header.Get("If-Unmodified-Since"); ius != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ius); err == nil && lastModified.Truncate(time.Second).After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if matchETag(inm, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && safe && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.Truncate(time.Second).After(t) {
+			return http.StatusNotModified
+		}
+	}
+
+	return 0
+}
+
+// matchETag checks if the etag is in the list of the header.
+// Weak comparison ignores the weak indicator. Strong comparison requires both to be strong.
+func matchETag(header string, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, candidate := range splitETags(header) {
+		a, b := candidate, etag
+		if weak {
+			a, b = strings.TrimPrefix(a, "W/"), strings.TrimPrefix(b, "W/")
+		} else if strings.HasPrefix(a, "W/") || strings.HasPrefix(b, "W/") {
+			continue
+		}
+		if a == b {
+			return true
+		}
+	}
+	return false
+}
+
+// splitETags splits a list of entity tags, considering that quoted values may contain commas
+func splitETags(header string) []string {
+	var tags []string
+	var start = -1
+	var quoted bool
+	for i := 0; i < len(header); i++ {
+		switch ch := header[i]; {
+		case ch == '"':
+			if start == -1 {
+				start = i
+			}
+			quoted = !quoted
+			if !quoted {
+				tags = append(tags, header[start:i+1])
+				start = -1
+			}
+		case ch == 'W' && !quoted && start == -1 && strings.HasPrefix(header[i:], `W/"`):
+			start = i
+			i++
+		}
+	}
+	return tags
+}
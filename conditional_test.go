@@ -0,0 +1,182 @@
+package maze
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchETag(t *testing.T) {
+	require.True(t, matchETag(`"a", "b"`, `"b"`, false))
+	require.True(t, matchETag(`*`, `"b"`, false))
+	require.False(t, matchETag(`*`, "", false))
+	require.True(t, matchETag(`W/"a"`, `"a"`, true))
+	require.False(t, matchETag(`W/"a"`, `"a"`, false))
+	require.True(t, matchETag(`"x,y", "z"`, `"x,y"`, false))
+	require.False(t, matchETag(`"a"`, `"b"`, true))
+}
+
+func TestConditional(t *testing.T) {
+	calls := 0
+	m := NewMaze()
+	m.GET("/greet", Conditional(ConditionalConfig{}), func(c IContext) error {
+		calls++
+		return c.JSON(http.StatusOK, "hello")
+	})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/greet", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, `"hello"`, w.Body.String())
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	r := httptest.NewRequest("GET", "/greet", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Empty(t, w.Body.String())
+	require.Equal(t, etag, w.Header().Get("ETag"))
+	require.Empty(t, w.Header().Get("Content-Type"))
+
+	r = httptest.NewRequest("GET", "/greet", nil)
+	r.Header.Set("If-None-Match", `"other"`)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 3, calls)
+}
+
+func TestConditionalVersion(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	calls := 0
+	m := NewMaze()
+	m.Push("/items/:id", Conditional(ConditionalConfig{
+		Version: func(c IContext) (string, time.Time, error) {
+			return `"v2"`, modified, nil
+		},
+	}), func(c IContext) error {
+		calls++
+		return c.TEXT(http.StatusOK, "item")
+	})
+
+	// not modified, without executing the handler
+	r := httptest.NewRequest("GET", "/items/1", nil)
+	r.Header.Set("If-None-Match", `W/"v2"`)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Equal(t, 0, calls)
+
+	r = httptest.NewRequest("GET", "/items/1", nil)
+	r.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Equal(t, 0, calls)
+
+	// stale version on update
+	r = httptest.NewRequest("PUT", "/items/1", nil)
+	r.Header.Set("If-Match", `"v1"`)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+	require.Equal(t, 0, calls)
+
+	r = httptest.NewRequest("PATCH", "/items/1", nil)
+	r.Header.Set("If-Unmodified-Since", modified.Add(-time.Hour).Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	r = httptest.NewRequest("PUT", "/items/1", nil)
+	r.Header.Set("If-Match", `"v2"`)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, `"v2"`, w.Header().Get("ETag"))
+	require.Equal(t, 1, calls)
+}
+
+// flushRecorder signals every flush, so that tests can wait for streamed responses
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushed chan struct{}
+}
+
+func newFlushRecorder() *flushRecorder {
+	return &flushRecorder{ResponseRecorder: httptest.NewRecorder(), flushed: make(chan struct{}, 1)}
+}
+
+func (r *flushRecorder) Flush() {
+	r.ResponseRecorder.Flush()
+	select {
+	case r.flushed <- struct{}{}:
+	default:
+	}
+}
+
+// serveStream serves the request until the first flush, returning the response once the request is cancelled
+func serveStream(m *Maze, r *http.Request) *httptest.ResponseRecorder {
+	ctx, cancel := context.WithCancel(r.Context())
+	w := newFlushRecorder()
+	done := make(chan struct{})
+	go func() {
+		m.ServeHTTP(w, r.WithContext(ctx))
+		close(done)
+	}()
+	select {
+	case <-w.flushed:
+	case <-done:
+	}
+	cancel()
+	<-done
+	return w.ResponseRecorder
+}
+
+func TestConditionalStreamsSse(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", Conditional(ConditionalConfig{}))
+	broker := NewSseBroker()
+	broker.OnConnect = func() (Sse, error) {
+		return NewSse("hello"), nil
+	}
+	m.GET("/events", broker.Serve)
+
+	// detected by the request and by the response
+	for _, accept := range []string{"text/event-stream", ""} {
+		r := httptest.NewRequest("GET", "/events", nil)
+		r.Header.Set("Accept", accept)
+		w := serveStream(m, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.True(t, w.Flushed)
+		require.Contains(t, w.Body.String(), "data: hello")
+		require.Empty(t, w.Header().Get("ETag"))
+	}
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	m := NewMaze()
+	m.GET("/doc", func(c IContext) error {
+		if CheckPreconditions(c, `"42"`, time.Time{}) {
+			return nil
+		}
+		return c.TEXT(http.StatusOK, "doc")
+	})
+
+	r := httptest.NewRequest("GET", "/doc", nil)
+	r.Header.Set("If-None-Match", `"41", "42"`)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotModified, w.Code)
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/doc", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, `"42"`, w.Header().Get("ETag"))
+}
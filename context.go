@@ -1,13 +1,14 @@
 package maze
 
 import (
+	"context"
 	"encoding/json"
-	"io/ioutil"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"strings"
 
-	"github.com/gorilla/schema"
-
 	tk "github.com/quintans/toolkit"
 )
 
@@ -15,19 +16,67 @@ type IContext interface {
 	Proceed() error
 	GetResponse() http.ResponseWriter
 	SetResponse(http.ResponseWriter)
+	// ResponseWriter gets the wrapper of the original response, recording what was sent to the client,
+	// even if the response was replaced with SetResponse
+	ResponseWriter() *ResponseWriter
 	GetRequest() *http.Request
+	// SetRequest replaces the request of the next filters, eg: to use a derived context
+	SetRequest(*http.Request)
+	// GetAttribute gets an attribute of this request. For typed access see Key.
 	GetAttribute(interface{}) interface{}
+	// SetAttribute sets an attribute of this request, also reachable with AttributeFrom(request.Context(), key)
 	SetAttribute(interface{}, interface{})
 	CurrentFilter() *Filter
-
-	// Payload put the json string in the request body into the struct passed as an interface{}
-	Payload(interface{}) error
+	// Logger returns the logger of this request
+	Logger() Logger
+	// SetLogger replaces the logger of this request, usually to add tags for everything downstream,
+	// eg: c.SetLogger(c.Logger().WithTags(maze.Tags{"user": user}))
+	SetLogger(Logger)
+	// RequestID returns the request ID set by the RequestID filter
+	RequestID() string
+	// SetRequestID sets the request ID, also adding it as a tag to the logger of this request
+	SetRequestID(string)
+	// Principal returns the authenticated identity of this request or nil if it is anonymous
+	Principal() *Principal
+	// SetPrincipal sets the authenticated identity of this request, also adding its name as a tag to the logger
+	SetPrincipal(*Principal)
+	// Claims returns the claims of the JWT that authenticated this request or nil if there is none
+	Claims() Claims
+	// CSRFToken returns the token set by the CSRF filter, to be sent back by forms and scripts
+	CSRFToken() string
+	// CSPNonce returns the Content-Security-Policy nonce of this request, set by the SecurityHeaders filter,
+	// to be used in the nonce attribute of inline scripts and styles
+	CSPNonce() string
+	// Route returns the rule of the last executed filter with a rule, eg: /rest/greet/sayhi/:Id
+	Route() string
+
+	// Payload decodes, as a stream, the json in the request body into the struct passed as an interface{}
+	Payload(interface{}, ...PayloadOption) error
+	// BodyLimit gets the maximum size of the request body
+	BodyLimit() int64
+	// SetBodyLimit sets the maximum size of the request body. Zero or less means no limit.
+	SetBodyLimit(int64)
+	// Body reads the whole request body, caching it so that it can be read again,
+	// by this method, BodyReader, Payload or through the request.
+	Body() ([]byte, error)
+	// BodyReader is like Body but returns a reader over the cached body
+	BodyReader() (io.Reader, error)
+	// Parts calls the function for each part of a multipart request, as they are read from the body
+	Parts(func(*multipart.Part) error) error
+	// SaveFiles saves the files of a multipart request, returning them and the other form values
+	SaveFiles(UploadConfig) ([]UploadedFile, Values, error)
+	// OnEnd registers a function to be called when the request ends
+	OnEnd(func())
+	// End calls, in reverse order, the functions registered with OnEnd. It is called by Maze.
+	End()
 	// PathVars put the path parameters in a url into the struct passed as an interface{}
 	PathVars(interface{}) error
 	// QueryVars put the parameters in the query part of a url into the struct passed as an interface{}
 	QueryVars(interface{}) error
 	// Vars
 	Vars(interface{}) error
+	// Converters gets the converters used by PathVars, QueryVars and Vars
+	Converters() *Converters
 	// Values gets a path parameter converter
 	PathValues() Values
 	// Values gets a parameter converter (path + query)
@@ -43,28 +92,52 @@ type IContext interface {
 var _ IContext = &MazeContext{}
 
 type MazeContext struct {
-	logger     Logger
-	decoder    *schema.Decoder
-	Response   http.ResponseWriter
-	Request    *http.Request
-	Attributes map[interface{}]interface{} // attributes only valid in this request
-	filters    []*Filter
-	filterPos  int
-	values     Values
-	pathValues Values
+	logger        Logger
+	converters    *Converters
+	Response      http.ResponseWriter
+	Request       *http.Request
+	writer        *ResponseWriter
+	Attributes    map[interface{}]interface{} // attributes only valid in this request
+	filters       []*Filter
+	filterPos     int
+	route         string
+	values        Values
+	pathValues    Values
+	bodyLimit     int64
+	bodyCache     *bodyCache
+	bodyThreshold int64
+	onEnd         []func()
 }
 
+// NewContext creates a context with the logger and the default settings
 func NewContext(logger Logger, w http.ResponseWriter, r *http.Request, filters []*Filter) *MazeContext {
-	decoder := schema.NewDecoder()
-	decoder.SetAliasTag("json")
+	return NewContextWithMaze(NewMaze(WithLogger(logger)), w, r, filters)
+}
+
+// NewContextWithMaze creates a context with the settings of the Maze
+func NewContextWithMaze(m *Maze, w http.ResponseWriter, r *http.Request, filters []*Filter) *MazeContext {
+	attributes := map[interface{}]interface{}{}
+	// attributes are also reachable through the request context
+	r = r.WithContext(context.WithValue(r.Context(), attributesKey{}, attributes))
+	rw := NewResponseWriter(w)
 	c := &MazeContext{
-		logger:     logger,
-		decoder:    decoder,
-		Response:   w,
-		Request:    r,
-		filterPos:  -1,
-		filters:    filters,
-		Attributes: map[interface{}]interface{}{},
+		logger:        m.logger,
+		converters:    m.converters,
+		Response:      rw,
+		Request:       r,
+		writer:        rw,
+		filterPos:     -1,
+		filters:       filters,
+		Attributes:    attributes,
+		bodyLimit:     m.bodyLimit,
+		bodyThreshold: m.bodyThreshold,
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &limitedBody{
+			ReadCloser:    r.Body,
+			contentLength: r.ContentLength,
+			limit:         &c.bodyLimit,
+		}
 	}
 	return c
 }
@@ -92,15 +165,21 @@ func (c *MazeContext) Next(mc IContext) error {
 	if next != nil {
 		if next.route == "" {
 			c.logger.Debugf("executing filter without rule")
-			return next.handler(mc)
+			return c.execute(mc, next)
 		} else {
 			// go to the next valid filter.
 			for i := c.filterPos; i < len(c.filters); i++ {
 				n := c.filters[i]
 				if n.IsValid(mc.GetRequest()) {
 					c.filterPos = i
-					c.logger.Debugf("executing filter %s", n)
-					return n.handler(mc)
+					c.route = n.String()
+					for _, fn := range routeListenersKey.Value(mc) {
+						fn(c.route)
+					}
+					if c.logger.Enabled(DebugLevel) {
+						c.logger.Debugf("executing filter %s", n)
+					}
+					return c.execute(mc, n)
 				}
 			}
 		}
@@ -109,6 +188,19 @@ func (c *MazeContext) Next(mc IContext) error {
 	return nil
 }
 
+// execute calls the filter handler, inside a span if the Tracing filter asked for filter spans
+func (c *MazeContext) execute(mc IContext, f *Filter) error {
+	if t, ok := traceKey.Get(mc); ok && t.filterSpans {
+		name := f.String()
+		if f.route == "" {
+			// filters chained to a rule are identified by their position
+			name = fmt.Sprintf("%s#%d", c.route, c.filterPos)
+		}
+		return t.traceFilter(mc, name, f.handler)
+	}
+	return f.handler(mc)
+}
+
 func (c *MazeContext) GetResponse() http.ResponseWriter {
 	return c.Response
 }
@@ -117,10 +209,18 @@ func (c *MazeContext) SetResponse(w http.ResponseWriter) {
 	c.Response = w
 }
 
+func (c *MazeContext) ResponseWriter() *ResponseWriter {
+	return c.writer
+}
+
 func (c *MazeContext) GetRequest() *http.Request {
 	return c.Request
 }
 
+func (c *MazeContext) SetRequest(r *http.Request) {
+	c.Request = r
+}
+
 func (c *MazeContext) GetAttribute(key interface{}) interface{} {
 	return c.Attributes[key]
 }
@@ -136,24 +236,123 @@ func (c *MazeContext) CurrentFilter() *Filter {
 	return nil
 }
 
-func (c *MazeContext) Payload(value interface{}) error {
+func (c *MazeContext) Logger() Logger {
+	return c.logger
+}
+
+func (c *MazeContext) SetLogger(logger Logger) {
+	c.logger = logger
+}
+
+func (c *MazeContext) RequestID() string {
+	return requestIDKey.Value(c)
+}
+
+func (c *MazeContext) SetRequestID(id string) {
+	requestIDKey.Set(c, id)
+	c.logger = c.logger.WithTags(Tags{"request_id": id})
+}
+
+func (c *MazeContext) Principal() *Principal {
+	return principalKey.Value(c)
+}
+
+func (c *MazeContext) SetPrincipal(p *Principal) {
+	principalKey.Set(c, p)
+	if p != nil {
+		c.logger = c.logger.WithTags(Tags{"principal": p.Name})
+	}
+}
+
+func (c *MazeContext) Claims() Claims {
+	return claimsKey.Value(c)
+}
+
+func (c *MazeContext) CSRFToken() string {
+	return csrfTokenKey.Value(c)
+}
+
+func (c *MazeContext) CSPNonce() string {
+	return cspNonceKey.Value(c)
+}
+
+// routeListenersKey holds the functions called with the route of each rule the request matches
+var routeListenersKey = NewKey[[]func(route string)]("route.listeners")
+
+// onRoute calls fn with the route of each rule matched by the next filters
+func onRoute(c IContext, fn func(route string)) {
+	routeListenersKey.Set(c, append(routeListenersKey.Value(c), fn))
+}
+
+func (c *MazeContext) Route() string {
+	return c.route
+}
+
+func (c *MazeContext) Payload(value interface{}, options ...PayloadOption) error {
 	if c.Request.Body != nil {
-		payload, err := ioutil.ReadAll(c.Request.Body)
+		return decodeJSON(c.Request.Body, value, options...)
+	}
+
+	return nil
+}
+
+func (c *MazeContext) Body() ([]byte, error) {
+	if err := c.cacheBody(); err != nil {
+		return nil, err
+	}
+	return c.bodyCache.bytes()
+}
+
+func (c *MazeContext) BodyReader() (io.Reader, error) {
+	if err := c.cacheBody(); err != nil {
+		return nil, err
+	}
+	return c.bodyCache.reader(), nil
+}
+
+// cacheBody reads the request body into the cache, if not already done,
+// and rewinds the request body so that it can be read again.
+func (c *MazeContext) cacheBody() error {
+	if c.bodyCache == nil {
+		body := c.Request.Body
+		if body == nil {
+			body = http.NoBody
+		}
+		bc, err := newBodyCache(body, c.bodyThreshold)
 		if err != nil {
 			return err
 		}
+		c.bodyCache = bc
+		c.OnEnd(bc.close)
+	}
+	c.Request.Body = c.bodyCache.reader()
+	return nil
+}
 
-		return json.Unmarshal(payload, value)
+func (c *MazeContext) OnEnd(fn func()) {
+	c.onEnd = append(c.onEnd, fn)
+}
+
+func (c *MazeContext) End() {
+	for i := len(c.onEnd) - 1; i >= 0; i-- {
+		c.onEnd[i]()
 	}
+	c.onEnd = nil
+}
 
-	return nil
+func (c *MazeContext) BodyLimit() int64 {
+	return c.bodyLimit
+}
+
+func (c *MazeContext) SetBodyLimit(limit int64) {
+	c.bodyLimit = limit
 }
 
 // PathVars put the path parameters in a url into the struct passed as an interface{}
 func (c *MazeContext) PathVars(value interface{}) error {
 	values := c.PathValues()
 	if len(values) > 0 {
-		return c.decoder.Decode(value, values)
+		return c.converters.Decode(value, values)
 	}
 
 	return nil
@@ -163,7 +362,7 @@ func (c *MazeContext) PathVars(value interface{}) error {
 func (c *MazeContext) QueryVars(value interface{}) error {
 	values := c.GetRequest().URL.Query()
 	if len(values) > 0 {
-		return c.decoder.Decode(value, values)
+		return c.converters.Decode(value, values)
 	}
 
 	return nil
@@ -172,7 +371,7 @@ func (c *MazeContext) QueryVars(value interface{}) error {
 func (c *MazeContext) Vars(value interface{}) error {
 	values := c.Values()
 	if len(values) > 0 {
-		return c.decoder.Decode(value, values)
+		return c.converters.Decode(value, values)
 	}
 
 	return nil
@@ -190,6 +389,10 @@ func (c *MazeContext) Load(value interface{}) error {
 	return nil
 }
 
+func (c *MazeContext) Converters() *Converters {
+	return c.converters
+}
+
 func (c *MazeContext) Values() Values {
 	if c.values != nil {
 		return c.values
@@ -0,0 +1,74 @@
+package maze
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/gorilla/schema"
+)
+
+// Converter converts a request value into a value of the type it was registered for.
+type Converter func(string) (interface{}, error)
+
+// Converters holds the value conversion rules of a Maze,
+// used when decoding path and query parameters into structs.
+type Converters struct {
+	decoder     *schema.Decoder
+	timeLayouts []string
+}
+
+func NewConverters() *Converters {
+	decoder := schema.NewDecoder()
+	decoder.SetAliasTag("json")
+	c := &Converters{
+		decoder:     decoder,
+		timeLayouts: DefaultTimeLayouts,
+	}
+	c.Register(time.Time{}, func(s string) (interface{}, error) {
+		return c.ParseTime(s)
+	})
+	c.Register(time.Duration(0), func(s string) (interface{}, error) {
+		return time.ParseDuration(s)
+	})
+	return c
+}
+
+// Register registers a converter for the type of value.
+// A converter registered for an already registered type replaces it.
+func (c *Converters) Register(value interface{}, converter Converter) {
+	t := reflect.TypeOf(value)
+	c.decoder.RegisterConverter(value, func(s string) reflect.Value {
+		v, err := converter(s)
+		if err != nil || v == nil {
+			// an invalid value signals a conversion error to the decoder
+			return reflect.Value{}
+		}
+		rv := reflect.ValueOf(v)
+		if !rv.Type().ConvertibleTo(t) {
+			return reflect.Value{}
+		}
+		return rv.Convert(t)
+	})
+}
+
+// SetTimeLayouts sets the accepted layouts, in order, when converting to time.Time.
+// UnixSeconds and UnixMillis can also be used.
+func (c *Converters) SetTimeLayouts(layouts ...string) {
+	c.timeLayouts = layouts
+}
+
+// ParseTime parses s with the first matching of the accepted time layouts
+func (c *Converters) ParseTime(s string) (time.Time, error) {
+	return ParseTime(s, c.timeLayouts...)
+}
+
+// TimeLayouts returns the accepted time layouts.
+// They can be passed to Values.AsTime, eg: c.Values().AsTime("at", c.Converters().TimeLayouts()...)
+func (c *Converters) TimeLayouts() []string {
+	return c.timeLayouts
+}
+
+// Decode puts the values into the struct passed as an interface{}
+func (c *Converters) Decode(dst interface{}, values map[string][]string) error {
+	return c.decoder.Decode(dst, values)
+}
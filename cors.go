@@ -0,0 +1,189 @@
+package maze
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quintans/toolkit/web"
+)
+
+const CORS_REJECTED = "MAZE07"
+
+// DefaultCORSMethods are the methods allowed when none is configured
+var DefaultCORSMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to make cross-origin requests.
+	// "*" allows any origin, except with AllowCredentials, and an origin may have one wildcard, eg: https://*.example.com
+	AllowedOrigins []string
+	// AllowOriginFunc, if set, is called for the origins not in AllowedOrigins
+	AllowOriginFunc func(origin string) bool
+	// AllowedMethods are the methods allowed in preflight requests. Default is DefaultCORSMethods.
+	AllowedMethods []string
+	// AllowedHeaders are the request headers allowed in preflight requests.
+	// If empty, the headers requested by the client are allowed.
+	AllowedHeaders []string
+	// ExposedHeaders are the response headers that the client script is allowed to read
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and authorization headers.
+	// It cannot be combined with "*", since that would let any site make authenticated requests.
+	AllowCredentials bool
+	// MaxAge is how long the preflight response can be cached by the client. Zero means not set.
+	MaxAge time.Duration
+}
+
+type corsPolicy struct {
+	anyOrigin        bool
+	origins          map[string]bool
+	patterns         [][2]string
+	allowOriginFunc  func(string) bool
+	methods          map[string]bool
+	allowedMethods   string
+	headers          map[string]bool
+	allowedHeaders   string
+	exposedHeaders   string
+	allowCredentials bool
+	maxAge           string
+}
+
+// CORS handles cross-origin requests, answering preflight OPTIONS requests itself.
+// It should be pushed for every method, eg: mz.Push("/api/*", maze.CORS(cfg)),
+// so that it is reached by the preflight requests.
+// Preflight requests from an origin, method or header that is not allowed are rejected with 403.
+func CORS(cfg CORSConfig) Handler {
+	for _, o := range cfg.AllowedOrigins {
+		if o == "*" && cfg.AllowCredentials {
+			panic("cors cannot allow credentials from any origin")
+		}
+	}
+	p := &corsPolicy{
+		origins:          map[string]bool{},
+		allowOriginFunc:  cfg.AllowOriginFunc,
+		methods:          map[string]bool{},
+		headers:          map[string]bool{},
+		allowCredentials: cfg.AllowCredentials,
+	}
+	for _, o := range cfg.AllowedOrigins {
+		o = strings.ToLower(o)
+		if o == "*" {
+			p.anyOrigin = true
+		} else if prefix, suffix, ok := strings.Cut(o, "*"); ok {
+			p.patterns = append(p.patterns, [2]string{prefix, suffix})
+		} else {
+			p.origins[o] = true
+		}
+	}
+	allowed := cfg.AllowedMethods
+	if len(allowed) == 0 {
+		allowed = DefaultCORSMethods
+	}
+	methods := make([]string, len(allowed))
+	for k, m := range allowed {
+		methods[k] = strings.ToUpper(m)
+		p.methods[methods[k]] = true
+	}
+	p.allowedMethods = strings.Join(methods, ", ")
+	for _, h := range cfg.AllowedHeaders {
+		p.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	p.allowedHeaders = strings.Join(cfg.AllowedHeaders, ", ")
+	p.exposedHeaders = strings.Join(cfg.ExposedHeaders, ", ")
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+
+	return p.handle
+}
+
+func (p *corsPolicy) handle(c IContext) error {
+	r := c.GetRequest()
+	h := c.GetResponse().Header()
+	preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	origin := r.Header.Get("Origin")
+
+	if preflight {
+		addVary(h, "Origin")
+		addVary(h, "Access-Control-Request-Method")
+		addVary(h, "Access-Control-Request-Headers")
+	} else if !p.anyOrigin {
+		// the response depends on the origin
+		addVary(h, "Origin")
+	}
+
+	if origin == "" {
+		return c.Proceed()
+	}
+	if !p.allowOrigin(origin) {
+		if preflight {
+			return web.NewHttpFail(http.StatusForbidden, CORS_REJECTED, "Origin not allowed")
+		}
+		// without the CORS headers the browser does not expose the response
+		return c.Proceed()
+	}
+
+	if p.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.allowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if p.exposedHeaders != "" {
+			h.Set("Access-Control-Expose-Headers", p.exposedHeaders)
+		}
+		return c.Proceed()
+	}
+
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	if !p.methods[method] {
+		return web.NewHttpFail(http.StatusForbidden, CORS_REJECTED, "Method not allowed")
+	}
+	requested := r.Header.Values("Access-Control-Request-Headers")
+	if len(p.headers) > 0 {
+		for _, v := range requested {
+			for _, name := range strings.Split(v, ",") {
+				name = strings.TrimSpace(name)
+				if name != "" && !p.headers[http.CanonicalHeaderKey(name)] {
+					return web.NewHttpFail(http.StatusForbidden, CORS_REJECTED, "Header not allowed: "+name)
+				}
+			}
+		}
+		h.Set("Access-Control-Allow-Headers", p.allowedHeaders)
+	} else if len(requested) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	h.Set("Access-Control-Allow-Methods", p.allowedMethods)
+	if p.maxAge != "" {
+		h.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	c.GetResponse().WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	o := strings.ToLower(origin)
+	if p.origins[o] {
+		return true
+	}
+	for _, pt := range p.patterns {
+		if len(o) > len(pt[0])+len(pt[1]) && strings.HasPrefix(o, pt[0]) && strings.HasSuffix(o, pt[1]) {
+			return true
+		}
+	}
+	return p.allowOriginFunc != nil && p.allowOriginFunc(origin)
+}
@@ -0,0 +1,94 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", CORS(CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "PUT"},
+		AllowedHeaders:   []string{"Content-Type", "X-Token"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	m.GET("/items", func(c IContext) error {
+		return c.TEXT(http.StatusOK, "items")
+	})
+
+	do := func(method, origin string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/items", nil)
+		r.Header.Set("Origin", origin)
+		for i := 0; i < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("GET", "https://app.example.com")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	require.Equal(t, "X-Request-Id", w.Header().Get("Access-Control-Expose-Headers"))
+	require.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	w = do("GET", "https://evil.com")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = do("OPTIONS", "https://api.example.org",
+		"Access-Control-Request-Method", "PUT",
+		"Access-Control-Request-Headers", "content-type, x-token")
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "https://api.example.org", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "GET, PUT", w.Header().Get("Access-Control-Allow-Methods"))
+	require.Equal(t, "Content-Type, X-Token", w.Header().Get("Access-Control-Allow-Headers"))
+	require.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+
+	w = do("OPTIONS", "https://api.example.org", "Access-Control-Request-Method", "DELETE")
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = do("OPTIONS", "https://api.example.org",
+		"Access-Control-Request-Method", "GET",
+		"Access-Control-Request-Headers", "X-Other")
+	require.Equal(t, http.StatusForbidden, w.Code)
+	w = do("OPTIONS", "https://example.org", "Access-Control-Request-Method", "GET")
+	require.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", CORS(CORSConfig{AllowedOrigins: []string{"*"}}))
+	m.GET("/items", func(c IContext) error {
+		return c.TEXT(http.StatusOK, "items")
+	})
+
+	r := httptest.NewRequest("OPTIONS", "/items", nil)
+	r.Header.Set("Origin", "https://any.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	r.Header.Set("Access-Control-Request-Headers", "X-Custom")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "X-Custom", w.Header().Get("Access-Control-Allow-Headers"))
+
+	r = httptest.NewRequest("GET", "/items", nil)
+	r.Header.Set("Origin", "https://any.com")
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, w.Header().Get("Vary"))
+
+	require.Panics(t, func() {
+		CORS(CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	})
+}
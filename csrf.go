@@ -0,0 +1,244 @@
+package maze
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/quintans/toolkit/web"
+)
+
+const (
+	CSRF_INVALID = "MAZE12"
+
+	DefaultCSRFCookie    = "csrf_token"
+	DefaultCSRFHeader    = "X-Csrf-Token"
+	DefaultCSRFFormField = "csrf_token"
+)
+
+// ErrCSRFInvalid is returned when an unsafe request has a missing or wrong CSRF token
+var ErrCSRFInvalid = web.NewHttpFail(http.StatusForbidden, CSRF_INVALID, "Invalid CSRF token")
+
+var csrfTokenKey = NewKey[string]("csrf.token")
+
+// CSRFStore keeps the synchronizer token of the session of the request, eg: in the server session
+type CSRFStore interface {
+	// Token returns the token of the session or an empty string if there is none
+	Token(c IContext) (string, error)
+	SetToken(c IContext, token string) error
+}
+
+type CSRFConfig struct {
+	// Store, if set, keeps the token server side (synchronizer token pattern).
+	// If not set, the token is kept in a cookie that must be echoed in the requests (double submit cookie pattern).
+	Store CSRFStore
+	// Secret, if set, signs the double submit cookie so that it cannot be planted by a sibling domain
+	Secret []byte
+	// CookieName is the double submit cookie. Default is DefaultCSRFCookie.
+	CookieName   string
+	CookiePath   string
+	CookieDomain string
+	// TrustProxy considers the X-Forwarded-Proto header to decide if the cookie is secure
+	TrustProxy bool
+	// Header is where the token is looked for first. Default is DefaultCSRFHeader.
+	Header string
+	// FormField is where the token is looked for in url encoded and multipart forms. Default is DefaultCSRFFormField.
+	FormField string
+	// ExemptMethods are the authentication methods that are not exposed to CSRF,
+	// because the browser does not send their credentials automatically.
+	// Default is AuthBearer and AuthAPIKey. API keys sent in cookies should not be exempted.
+	ExemptMethods []string
+	// Exempt, if set, exempts other requests from validation
+	Exempt func(c IContext) bool
+}
+
+// CSRF protects against cross-site request forgery, requiring unsafe requests
+// to carry the token in a header or in a form field.
+// The token is available to templates through IContext.CSRFToken.
+// Requests authenticated with the ExemptMethods are not validated, so this filter should come after the authentication filters.
+// Invalid requests are rejected with 403.
+// In multipart forms the token field must be the first part,
+// so that only the beginning of the upload is read before the handler.
+func CSRF(cfg CSRFConfig) Handler {
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultCSRFCookie
+	}
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = "/"
+	}
+	if cfg.Header == "" {
+		cfg.Header = DefaultCSRFHeader
+	}
+	if cfg.FormField == "" {
+		cfg.FormField = DefaultCSRFFormField
+	}
+	if cfg.ExemptMethods == nil {
+		cfg.ExemptMethods = []string{AuthBearer, AuthAPIKey}
+	}
+
+	return func(c IContext) error {
+		if p := c.Principal(); p != nil {
+			for _, m := range cfg.ExemptMethods {
+				if p.Method == m {
+					return c.Proceed()
+				}
+			}
+		}
+
+		var expected string
+		var err error
+		if cfg.Store != nil {
+			expected, err = cfg.Store.Token(c)
+		} else {
+			expected = cookieToken(c.GetRequest(), cfg)
+		}
+		if err != nil {
+			return err
+		}
+
+		r := c.GetRequest()
+		unsafe := r.Method != http.MethodGet && r.Method != http.MethodHead &&
+			r.Method != http.MethodOptions && r.Method != http.MethodTrace
+		if unsafe && (cfg.Exempt == nil || !cfg.Exempt(c)) {
+			if expected == "" {
+				return ErrCSRFInvalid
+			}
+			given, err := submittedToken(c, cfg)
+			if err != nil {
+				return err
+			}
+			if subtle.ConstantTimeCompare([]byte(given), []byte(expected)) != 1 {
+				return ErrCSRFInvalid
+			}
+		}
+
+		if expected == "" {
+			expected = newCSRFToken(cfg.Secret)
+			if cfg.Store != nil {
+				err = cfg.Store.SetToken(c, expected)
+			} else {
+				http.SetCookie(c.GetResponse(), &http.Cookie{
+					Name:     cfg.CookieName,
+					Value:    expected,
+					Path:     cfg.CookiePath,
+					Domain:   cfg.CookieDomain,
+					Secure:   isHTTPS(r, cfg.TrustProxy),
+					SameSite: http.SameSiteLaxMode,
+				})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		csrfTokenKey.Set(c, expected)
+		return c.Proceed()
+	}
+}
+
+func newCSRFToken(secret []byte) string {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b[:])
+	if len(secret) > 0 {
+		token += "." + signCSRFToken(secret, token)
+	}
+	return token
+}
+
+func signCSRFToken(secret []byte, token string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cookieToken returns the double submit cookie, if present and correctly signed
+func cookieToken(r *http.Request, cfg CSRFConfig) string {
+	cookie, err := r.Cookie(cfg.CookieName)
+	if err != nil || cookie.Value == "" {
+		return ""
+	}
+	if len(cfg.Secret) > 0 {
+		token, sig, ok := strings.Cut(cookie.Value, ".")
+		if !ok || !hmac.Equal([]byte(sig), []byte(signCSRFToken(cfg.Secret, token))) {
+			return ""
+		}
+	}
+	return cookie.Value
+}
+
+// csrfMultipartPrefix is the maximum of a multipart body read to find the token
+const csrfMultipartPrefix = 64 << 10
+
+// submittedToken looks for the token in the header and then in the form, without consuming the request body
+func submittedToken(c IContext, cfg CSRFConfig) (string, error) {
+	r := c.GetRequest()
+	if token := r.Header.Get(cfg.Header); token != "" {
+		return token, nil
+	}
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", nil
+	}
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		body, err := c.Body()
+		if err != nil {
+			return "", err
+		}
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", nil
+		}
+		return values.Get(cfg.FormField), nil
+	case "multipart/form-data":
+		if r.Body == nil {
+			return "", nil
+		}
+		// only the beginning of the body is read, to be replayed to the handler,
+		// so the token field must be the first part
+		var prefix bytes.Buffer
+		body := r.Body
+		mr := multipart.NewReader(io.TeeReader(io.LimitReader(body, csrfMultipartPrefix), &prefix), params["boundary"])
+		token, err := firstPartToken(mr, cfg.FormField)
+		r.Body = &replayBody{Reader: io.MultiReader(&prefix, body), Closer: body}
+		if errors.Is(err, ErrBodyTooLarge) {
+			return "", err
+		}
+		return token, nil
+	}
+	return "", nil
+}
+
+// firstPartToken reads the token field, if it is the first part
+func firstPartToken(mr *multipart.Reader, field string) (string, error) {
+	part, err := mr.NextPart()
+	if err != nil {
+		return "", err
+	}
+	if part.FormName() != field || part.FileName() != "" {
+		return "", nil
+	}
+	b, err := io.ReadAll(io.LimitReader(part, 1024))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// replayBody reads what was already read from the body before the rest of it
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,169 @@
+package maze
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSRFDoubleSubmit(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", CSRF(CSRFConfig{Secret: []byte("secret")}))
+	m.GET("/form", func(c IContext) error {
+		return c.TEXT(http.StatusOK, c.CSRFToken())
+	})
+	m.POST("/submit", func(c IContext) error {
+		// the body is still available
+		body, err := c.Body()
+		if err != nil {
+			return err
+		}
+		return c.TEXT(http.StatusOK, len(body))
+	})
+	m.POST("/upload", func(c IContext) error {
+		// the upload was not cached
+		require.Nil(t, c.(*MazeContext).bodyCache)
+		body, err := io.ReadAll(c.GetRequest().Body)
+		if err != nil {
+			return err
+		}
+		return c.TEXT(http.StatusOK, len(body))
+	})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/form", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	token := w.Body.String()
+	require.NotEmpty(t, token)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, token, cookies[0].Value)
+
+	post := func(body string, contentType string, header string, cookie string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/submit", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		if header != "" {
+			r.Header.Set(DefaultCSRFHeader, header)
+		}
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: DefaultCSRFCookie, Value: cookie})
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	require.Equal(t, http.StatusOK, post("{}", "application/json", token, token).Code)
+	require.Equal(t, http.StatusForbidden, post("{}", "application/json", "", token).Code)
+	require.Equal(t, http.StatusForbidden, post("{}", "application/json", token, "").Code)
+	require.Equal(t, http.StatusForbidden, post("{}", "application/json", token+"x", token).Code)
+	// a planted cookie without a valid signature
+	require.Equal(t, http.StatusForbidden, post("{}", "application/json", "forged.sig", "forged.sig").Code)
+
+	form := url.Values{DefaultCSRFFormField: {token}, "name": {"ana"}}.Encode()
+	w = post(form, "application/x-www-form-urlencoded", "", token)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, strconv.Itoa(len(form)), w.Body.String())
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	require.NoError(t, mw.WriteField(DefaultCSRFFormField, token))
+	fw, err := mw.CreateFormFile("content", "a.txt")
+	require.NoError(t, err)
+	fw.Write([]byte("hello"))
+	require.NoError(t, mw.Close())
+	size := buf.Len()
+	w = post(buf.String(), mw.FormDataContentType(), "", token)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, strconv.Itoa(size), w.Body.String())
+
+	upload := func(tokenFirst bool) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		if tokenFirst {
+			require.NoError(t, mw.WriteField(DefaultCSRFFormField, token))
+		}
+		fw, err := mw.CreateFormFile("content", "big.txt")
+		require.NoError(t, err)
+		// bigger than what is read to find the token
+		fw.Write(bytes.Repeat([]byte("a"), 2*csrfMultipartPrefix))
+		if !tokenFirst {
+			require.NoError(t, mw.WriteField(DefaultCSRFFormField, token))
+		}
+		require.NoError(t, mw.Close())
+		r := httptest.NewRequest("POST", "/upload", &buf)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		r.AddCookie(&http.Cookie{Name: DefaultCSRFCookie, Value: token})
+		size := buf.Len()
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		if w.Code == http.StatusOK {
+			require.Equal(t, strconv.Itoa(size), w.Body.String())
+		}
+		return w
+	}
+	require.Equal(t, http.StatusOK, upload(true).Code)
+	// the token must come before the files
+	require.Equal(t, http.StatusForbidden, upload(false).Code)
+}
+
+type memoryCSRFStore struct {
+	token string
+}
+
+func (s *memoryCSRFStore) Token(c IContext) (string, error) {
+	return s.token, nil
+}
+
+func (s *memoryCSRFStore) SetToken(c IContext, token string) error {
+	s.token = token
+	return nil
+}
+
+func TestCSRFSynchronizer(t *testing.T) {
+	secret := []byte("secret")
+	store := &memoryCSRFStore{}
+	m := NewMaze()
+	m.Push("/api/*", JWT(JWTConfig{Keys: StaticKeys{"": secret}}))
+	m.Push("/*", CSRF(CSRFConfig{Store: store}))
+	m.GET("/form", func(c IContext) error {
+		return c.TEXT(http.StatusOK, c.CSRFToken())
+	})
+	m.POST("/submit", func(c IContext) error {
+		return nil
+	})
+	m.POST("/api/submit", func(c IContext) error {
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/form", nil))
+	token := w.Body.String()
+	require.Equal(t, store.token, token)
+	require.Empty(t, w.Result().Cookies())
+
+	r := httptest.NewRequest("POST", "/submit", nil)
+	r.Header.Set(DefaultCSRFHeader, token)
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("POST", "/submit", nil))
+	require.Equal(t, http.StatusForbidden, w.Code)
+
+	// token authenticated requests are exempted
+	r = httptest.NewRequest("POST", "/api/submit", nil)
+	r.Header.Set("Authorization", "Bearer "+signJWT(t, "HS256", "", secret, map[string]interface{}{"sub": "ana"}))
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+}
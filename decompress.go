@@ -0,0 +1,109 @@
+package maze
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/quintans/toolkit/web"
+)
+
+const (
+	BODY_ENCODING = "MAZE06"
+
+	// DefaultDecompressLimit is the maximum size of a decompressed request body
+	DefaultDecompressLimit int64 = 10 << 20
+)
+
+type DecompressConfig struct {
+	// Limit is the maximum size of the decompressed body, protecting against zip bombs.
+	// Default is DefaultDecompressLimit. Reading beyond it fails with ErrBodyTooLarge.
+	Limit int64
+}
+
+// Decompress transparently decompresses request bodies with a gzip, deflate or br Content-Encoding,
+// so that later filters, Payload included, read the decoded body.
+// Requests with other encodings are rejected with 415 and corrupt bodies fail with 400.
+func Decompress(cfg DecompressConfig) Handler {
+	limit := cfg.Limit
+	if limit <= 0 {
+		limit = DefaultDecompressLimit
+	}
+
+	return func(c IContext) error {
+		r := c.GetRequest()
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if encoding == "" || encoding == "identity" || r.Body == nil || r.Body == http.NoBody {
+			return c.Proceed()
+		}
+
+		src := &encodedBody{Reader: r.Body}
+		var dec io.ReadCloser
+		switch encoding {
+		case EncodingGzip, "x-gzip":
+			zr, err := gzip.NewReader(src)
+			if err != nil {
+				return src.fail(err)
+			}
+			dec = zr
+		case EncodingDeflate:
+			// HTTP deflate is the zlib format (RFC 9110 section 8.4.1.2)
+			zr, err := zlib.NewReader(src)
+			if err != nil {
+				return src.fail(err)
+			}
+			dec = zr
+		case EncodingBrotli:
+			dec = io.NopCloser(brotli.NewReader(src))
+		default:
+			return web.NewHttpFail(http.StatusUnsupportedMediaType, BODY_ENCODING, "unsupported content encoding: "+encoding)
+		}
+
+		// the server closes the original body
+		r.Body = &limitedBody{ReadCloser: &decodedBody{ReadCloser: dec, src: src}, contentLength: -1, limit: &limit}
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+		return c.Proceed()
+	}
+}
+
+// encodedBody keeps the error of reading the encoded body,
+// to tell it apart from the errors of decoding it
+type encodedBody struct {
+	io.Reader
+	err error
+}
+
+func (b *encodedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// fail returns the errors of decoding as a 400
+func (b *encodedBody) fail(err error) error {
+	if b.err != nil && errors.Is(err, b.err) {
+		return err
+	}
+	return web.NewHttpFail(http.StatusBadRequest, BODY_ENCODING, "invalid encoded body: "+err.Error())
+}
+
+type decodedBody struct {
+	io.ReadCloser
+	src *encodedBody
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		err = b.src.fail(err)
+	}
+	return n, err
+}
@@ -0,0 +1,82 @@
+package maze
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompress(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", Decompress(DecompressConfig{Limit: 100}))
+	m.POST("/*", func(c IContext) error {
+		var v string
+		if err := c.Payload(&v); err != nil {
+			return err
+		}
+		return c.TEXT(http.StatusOK, v)
+	})
+
+	post := func(encoding string, body []byte) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		r.Header.Set("Content-Encoding", encoding)
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`"hello"`))
+	zw.Close()
+	w := post("gzip", gz.Bytes())
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "hello", w.Body.String())
+
+	var zl bytes.Buffer
+	dw := zlib.NewWriter(&zl)
+	dw.Write([]byte(`"hey"`))
+	dw.Close()
+	w = post("deflate", zl.Bytes())
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "hey", w.Body.String())
+
+	var br bytes.Buffer
+	bw := brotli.NewWriter(&br)
+	bw.Write([]byte(`"hi"`))
+	bw.Close()
+	w = post("br", br.Bytes())
+	require.Equal(t, "hi", w.Body.String())
+
+	// zip bomb
+	gz.Reset()
+	zw = gzip.NewWriter(&gz)
+	zw.Write([]byte(`"` + strings.Repeat("a", 10000) + `"`))
+	zw.Close()
+	require.Less(t, gz.Len(), 100)
+	w = post("gzip", gz.Bytes())
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = post("compress", []byte("x"))
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	w = post("gzip", []byte("not gzip"))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = post("deflate", []byte("not deflate"))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// truncated streams
+	w = post("deflate", zl.Bytes()[:4])
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = post("br", br.Bytes()[:2])
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	w = post("gzip", gz.Bytes()[:12])
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
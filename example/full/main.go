@@ -2,15 +2,14 @@ package main
 
 import (
 	"fmt"
-	"io"
-	"io/ioutil"
+	"html/template"
 	"net/http"
-	"os"
 	"runtime/debug"
 	"strconv"
 	"time"
 
 	"github.com/quintans/maze"
+	"github.com/quintans/maze/mazelogrus"
 	"github.com/quintans/toolkit/web"
 	"github.com/sirupsen/logrus"
 )
@@ -19,18 +18,11 @@ const (
 	COUNTER = "counter"
 )
 
-var logger = maze.NewLogrus(logrus.StandardLogger())
-
-// authorization filter
-func UnauthorizedFilter(ctx maze.IContext) error {
-	logger.Debugf("executing UnauthorizedFilter()")
-	http.Error(ctx.GetResponse(), "Unauthorized Access", http.StatusUnauthorized)
-	return nil
-}
+var logger = mazelogrus.NewLogrus(logrus.StandardLogger())
 
 // end point
 func counter(c maze.IContext) error {
-	logger.Debugf("executing counter()")
+	c.Logger().Debugf("executing counter()")
 
 	ctx := c.(*AppCtx)
 	session := ctx.Session
@@ -45,28 +37,31 @@ func counter(c maze.IContext) error {
 	return nil
 }
 
-// dummy test
-func mark(ctx maze.IContext) error {
-	logger.Debugf("requesting", ctx.GetRequest().URL.Path)
-	return ctx.Proceed()
-}
-
-func hasRole(roles ...string) func(ctx maze.IContext) error {
-	return func(ctx maze.IContext) error {
-		logger.Debugf("executing hasRole(%s)", roles)
+// users, passwords and roles
+var (
+	credentials = maze.StaticCredentials(map[string]string{"admin": "admin", "user": "user"})
+	roles       = map[string][]string{"admin": {"admin", "super"}, "user": {"user"}}
+)
 
-		fmt.Printf(">>> checking for role(s) %s <<<\n\n", roles)
-		return ctx.Proceed()
-	}
-}
+// authenticate authenticates with basic auth, adding the roles to the principal
+var authenticate = maze.BasicAuth(maze.BasicAuthConfig{
+	Realm: "maze",
+	Verifier: func(c maze.IContext, username, password string) (*maze.Principal, error) {
+		p, err := credentials(c, username, password)
+		if p != nil {
+			p.Roles = roles[username]
+		}
+		return p, err
+	},
+})
 
 // redirects / to homte.html
 func HomeHandler(ctx maze.IContext) error {
 	w := ctx.GetResponse()
 	r := ctx.GetRequest()
-	logger.Debugf("executing HomeHandler() on %s", r.URL.Path)
+	ctx.Logger().Debugf("executing HomeHandler() on %s", r.URL.Path)
 
-	logger.Debugf("redirecting...")
+	ctx.Logger().Debugf("redirecting...")
 	http.Redirect(w, r, "/static/home.html", http.StatusMovedPermanently)
 	return nil
 }
@@ -74,40 +69,49 @@ func HomeHandler(ctx maze.IContext) error {
 // 16MB
 const post_limit = 1 << 24
 
-// limits the body of a post
-func limit(ctx maze.IContext) error {
-	logger.Debugf("executing limit()")
-
-	ctx.GetRequest().Body = http.MaxBytesReader(ctx.GetResponse(), ctx.GetRequest().Body, post_limit)
-	return ctx.Proceed()
+var uploadForm = template.Must(template.New("upload").Parse(`<html>
+<head>
+	<title>Upload</title>
+</head>
+<body>
+	<h1>Go UPLOAD Example</h1>
+	<form action="/upload/" enctype="multipart/form-data" method="post">
+		<!-- the CSRF token must be the first field, so that it is found without reading the upload -->
+		<input name="csrf_token" type="hidden" value="{{.}}"/>
+		<div>
+			<input name="content" type="file"/>
+		</div>
+		<div>
+			<input id="do" type="submit" value="Upload"/>
+		</div>
+	</form>
+</body>
+</html>`))
+
+// upload form, with the CSRF token
+func uploadPage(ctx maze.IContext) error {
+	ctx.GetResponse().Header().Set("Content-Type", "text/html; charset=utf-8")
+	return uploadForm.Execute(ctx.GetResponse(), ctx.CSRFToken())
 }
 
 // file upload
 func upload(ctx maze.IContext) error {
-	logger.Debugf("executing upload()")
+	ctx.Logger().Debugf("executing upload()")
 
-	w := ctx.GetResponse()
-	r := ctx.GetRequest()
-	fn, _, err := r.FormFile("content")
+	// files are saved in the temporary dir and removed at the end of the request
+	files, _, err := ctx.SaveFiles(maze.UploadConfig{
+		MaxFiles:    1,
+		MaxFileSize: post_limit,
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return nil
+		return err
 	}
-	defer fn.Close()
-
-	//data, err := ioutil.ReadAll(file)
-	//if err != nil {
-	//	return err
-	//}
-
-	f, _ := ioutil.TempFile("./upload", "upload")
-	defer f.Close()
-	defer os.Remove(f.Name())
-	io.Copy(f, fn)
 
 	// do something with file, like storing in a database
 
-	fmt.Fprintln(ctx.GetResponse(), "uploaded to ", f.Name())
+	for _, f := range files {
+		fmt.Fprintln(ctx.GetResponse(), "uploaded", f.Filename, "to", f.Path)
+	}
 	return nil
 }
 
@@ -171,15 +175,24 @@ func main() {
 	}()
 
 	// creates maze with context factory.
-	mz := maze.NewMaze(maze.WithContextFactory(func(logrus maze.Logger, w http.ResponseWriter, r *http.Request, filters []*maze.Filter) maze.IContext {
-		ctx := new(AppCtx)
-		ctx.MazeContext = maze.NewContext(logger, w, r, filters)
-		return ctx
-	}))
-	// limits size
-	mz.Push("/*", limit)
-	// logs request path
-	mz.Push("/*", mark)
+	mz := maze.NewMaze(
+		maze.WithLogger(logger),
+		// limits size
+		maze.WithBodyLimit(post_limit),
+		maze.WithMazeContextFactory(func(m *maze.Maze, w http.ResponseWriter, r *http.Request, filters []*maze.Filter) maze.IContext {
+			ctx := new(AppCtx)
+			ctx.MazeContext = maze.NewContextWithMaze(m, w, r, filters)
+			return ctx
+		}),
+	)
+	// request metrics exposed at /metrics
+	maze.NewMetrics(maze.MetricsConfig{}).Register(mz)
+	// logs every request
+	mz.Push("/*", maze.AccessLog(nil, maze.AccessLogConfig{Format: maze.CombinedLogFormat}))
+	// sets the request ID and adds it to the request logger
+	mz.Push("/*", maze.RequestID(maze.RequestIDConfig{}))
+	// compresses responses
+	mz.Push("/*", maze.Compress(maze.CompressConfig{}))
 
 	// handles server sessions
 	sessions := web.NewSessions(web.SessionsConfig{
@@ -188,7 +201,7 @@ func main() {
 	})
 	// if this filter is used it has to be the first to write to response
 	mz.Push("/app/*", func(c maze.IContext) error {
-		logger.Debugf("executing SessionFilter()")
+		c.Logger().Debugf("executing SessionFilter()")
 
 		ctx := c.(*AppCtx)
 		// (re)writes the session cookie to the response
@@ -200,31 +213,35 @@ func main() {
 	//.PushF("/app/xxx*", hello)
 
 	// secure content
-	mz.Push("/static/private/*", UnauthorizedFilter)
+	mz.Push("/static/private/*", authenticate)
 
 	// delivering static content and preventing malicious access
 	fs := web.OnlyFilesFS{Fs: http.Dir("./")}
 	fileServer := http.FileServer(fs)
 	// http.Handle("/static/", http.FileServer(fs))
 	mz.GET("/static/*", func(ctx maze.IContext) error {
-		logger.Debugf("executing static()")
+		ctx.Logger().Debugf("executing static()")
 		fileServer.ServeHTTP(ctx.GetResponse(), ctx.GetRequest())
 		return nil
 	})
 	// or
 	// mz.Static("/static/*", "./")
 
-	mz.Push("/upload/*", upload)
+	// protects the upload form against cross-site request forgery
+	mz.Push("/upload/*", maze.CSRF(maze.CSRFConfig{}))
+	mz.GET("/upload/*", uploadPage)
+	mz.POST("/upload/*", upload)
 	// JSON-RPC services
 	greetingsService := new(GreetingService)
-	rpc, err := maze.NewJsonRpc(logger, greetingsService)
+	rpc, err := maze.NewJsonRpcService(greetingsService)
 	if err != nil {
 		panic(err)
 	}
-	rpc.SetActionFilters("SayHello", hasRole("user", "admin")) // filters specific action of the service
+	rpc.RequireAction("SayHello", maze.RequireRoles("user", "admin")) // permissions of a specific action of the service
+	mz.Push("/json/greeting/*", authenticate)
 	mz.Add(rpc.Build("/json/greeting")...)
 
-	mz.Push("/rest/greet/*", hasRole("super"))
+	mz.Push("/rest/greet/*", authenticate, maze.RequireRoles("super"))
 	// the applied rule will be "/rest/greet/sayhi/:Id"
 	mz.GET("sayhi/:Id", greetingsService.SayHi)
 	// guard - if this valid and it reached here it means the service endpoint is invalid
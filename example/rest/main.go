@@ -46,9 +46,9 @@ func (ac *AppCtx) Reply(value interface{}) error {
 
 func main() {
 	// creates maze with specialized context factory.
-	mz := maze.NewMaze(maze.WithContextFactory(func(logger maze.Logger, w http.ResponseWriter, r *http.Request, filters []*maze.Filter) maze.IContext {
+	mz := maze.NewMaze(maze.WithMazeContextFactory(func(m *maze.Maze, w http.ResponseWriter, r *http.Request, filters []*maze.Filter) maze.IContext {
 		ctx := new(AppCtx)
-		ctx.MazeContext = maze.NewContext(logger, w, r, filters)
+		ctx.MazeContext = maze.NewContextWithMaze(m, w, r, filters)
 		return ctx
 	}))
 
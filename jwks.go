@@ -0,0 +1,262 @@
+package maze
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultJWKSRefresh is how long the keys are cached before being reloaded
+	DefaultJWKSRefresh = time.Hour
+	// DefaultJWKSMinRefresh is the minimum time between reloads triggered by unknown key ids
+	DefaultJWKSMinRefresh = time.Minute
+)
+
+type JWKSConfig struct {
+	// Source is the path of a file, a file:// URL or an http(s):// URL of the JWKS document
+	Source string
+	// Refresh is how long the keys are cached. Default is DefaultJWKSRefresh.
+	Refresh time.Duration
+	// MinRefresh limits the reloads triggered by tokens signed with unknown keys,
+	// so that keys rotated at the source are picked up without letting bogus tokens hammer it.
+	// Default is DefaultJWKSMinRefresh.
+	MinRefresh time.Duration
+	// Client fetches http sources. Default has a 10 seconds timeout.
+	Client *http.Client
+}
+
+// JWK is a key of a JWKS document
+type JWK struct {
+	Kid string
+	Alg string
+	// Key is a []byte, *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey
+	Key interface{}
+}
+
+// JWKS is a KeySet loaded from a JWKS document, reloaded periodically and when a token uses an unknown key id
+type JWKS struct {
+	mu         sync.Mutex
+	source     string
+	refresh    time.Duration
+	minRefresh time.Duration
+	client     *http.Client
+	keys       []JWK
+	loaded     time.Time
+	attempted  time.Time
+}
+
+var _ KeySet = &JWKS{}
+
+// NewJWKS creates the key set, failing if the document cannot be loaded
+func NewJWKS(cfg JWKSConfig) (*JWKS, error) {
+	j := &JWKS{
+		source:     cfg.Source,
+		refresh:    cfg.Refresh,
+		minRefresh: cfg.MinRefresh,
+		client:     cfg.Client,
+	}
+	if j.refresh <= 0 {
+		j.refresh = DefaultJWKSRefresh
+	}
+	if j.minRefresh <= 0 {
+		j.minRefresh = DefaultJWKSMinRefresh
+	}
+	if j.client == nil {
+		j.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if err := j.Reload(); err != nil {
+		return nil, err
+	}
+	j.attempted = j.loaded
+	return j, nil
+}
+
+// Reload loads the document from the source, replacing the keys
+func (j *JWKS) Reload() error {
+	data, err := j.read()
+	if err != nil {
+		return fmt.Errorf("unable to read the JWKS from %s: %w", j.source, err)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	j.keys = keys
+	j.loaded = time.Now()
+	j.mu.Unlock()
+	return nil
+}
+
+func (j *JWKS) read() ([]byte, error) {
+	if !strings.HasPrefix(j.source, "http://") && !strings.HasPrefix(j.source, "https://") {
+		return os.ReadFile(strings.TrimPrefix(j.source, "file://"))
+	}
+	res, err := j.client.Get(j.source)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+}
+
+func (j *JWKS) Key(kid string, alg string) (interface{}, error) {
+	j.mu.Lock()
+	key, found := findJWK(j.keys, kid, alg)
+	now := time.Now()
+	stale := now.Sub(j.loaded) >= j.refresh
+	// an unknown key id may mean that the keys were rotated
+	reload := (stale || !found) && now.Sub(j.attempted) >= j.minRefresh
+	if reload {
+		j.attempted = now
+	}
+	j.mu.Unlock()
+
+	if reload {
+		if err := j.Reload(); err != nil {
+			if found {
+				// keeps using the cached keys while the source is unavailable
+				return key, nil
+			}
+			return nil, err
+		}
+		j.mu.Lock()
+		key, found = findJWK(j.keys, kid, alg)
+		j.mu.Unlock()
+	}
+	if !found {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// findJWK finds the key with the id that can be used with the algorithm
+func findJWK(keys []JWK, kid string, alg string) (interface{}, bool) {
+	for _, k := range keys {
+		if k.Kid == kid && (k.Alg == "" || k.Alg == alg) {
+			return k.Key, true
+		}
+	}
+	return nil, false
+}
+
+type jwkJSON struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses a JWKS document, ignoring the keys that are not for signatures or of unsupported types
+func ParseJWKS(data []byte) ([]JWK, error) {
+	var doc struct {
+		Keys []jwkJSON `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	keys := make([]JWK, 0, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.key()
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWK %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			keys = append(keys, JWK{Kid: k.Kid, Alg: k.Alg, Key: key})
+		}
+	}
+	return keys, nil
+}
+
+func (k jwkJSON) key() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	}
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("missing value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,408 @@
+package maze
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultJWTClockSkew is the tolerance when checking exp and nbf
+	DefaultJWTClockSkew = time.Minute
+	DefaultBearerRealm  = "api"
+
+	AuthBearer = "bearer"
+)
+
+var claimsKey = NewKey[Claims]("jwt.claims")
+
+// Claims are the claims of a verified JWT
+type Claims map[string]interface{}
+
+func (c Claims) AsString(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// AsStrings gets a claim that can be an array of strings or a single string
+func (c Claims) AsStrings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		ss := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+		return ss
+	}
+	return nil
+}
+
+// AsTime gets a claim that is a NumericDate, in seconds since the epoch
+func (c Claims) AsTime(name string) (time.Time, bool) {
+	var secs float64
+	switch v := c[name].(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		secs = f
+	case float64:
+		secs = v
+	default:
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(secs*float64(time.Second))), true
+}
+
+func (c Claims) Subject() string {
+	return c.AsString("sub")
+}
+
+func (c Claims) Issuer() string {
+	return c.AsString("iss")
+}
+
+func (c Claims) Audience() []string {
+	return c.AsStrings("aud")
+}
+
+// Scopes gets the space separated scope claim or, if absent, the scp claim
+func (c Claims) Scopes() []string {
+	if s := c.AsString("scope"); s != "" {
+		return strings.Fields(s)
+	}
+	return c.AsStrings("scp")
+}
+
+// Roles gets the roles claim
+func (c Claims) Roles() []string {
+	return c.AsStrings("roles")
+}
+
+// KeySet provides the keys that verify the JWT signatures
+type KeySet interface {
+	// Key returns the key with the id, for the algorithm.
+	// It is a []byte for HMAC, *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+	Key(kid string, alg string) (interface{}, error)
+}
+
+// StaticKeys is a KeySet with fixed keys by key id.
+// A token without a key id is verified with the key of the empty id or, if there is only one, with that one.
+type StaticKeys map[string]interface{}
+
+func (s StaticKeys) Key(kid string, alg string) (interface{}, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(s) == 1 {
+		for _, key := range s {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+type JWTConfig struct {
+	Keys KeySet
+	// Algorithms are the accepted algorithms. Default is any supported algorithm that matches the type of the key.
+	Algorithms []string
+	// Issuer, if set, must be the iss claim
+	Issuer string
+	// Audience, if set, must include one of the aud claim values
+	Audience []string
+	// ClockSkew is the tolerance when checking exp and nbf. Default is DefaultJWTClockSkew.
+	ClockSkew time.Duration
+	// RequireExpiration rejects tokens without exp
+	RequireExpiration bool
+	// Realm is sent in the challenge. Default is DefaultBearerRealm.
+	Realm string
+	// Principal maps the claims to the principal.
+	// Default uses sub as the name, the roles claim as roles and the scope or scp claim as scopes.
+	Principal func(Claims) *Principal
+}
+
+// JWT authenticates the request with a bearer JWT in the Authorization header,
+// setting the claims and the principal in the context.
+// Requests without a valid token are rejected with 401 and a WWW-Authenticate challenge.
+func JWT(cfg JWTConfig) Handler {
+	if cfg.Keys == nil {
+		panic("jwt requires Keys")
+	}
+	if cfg.ClockSkew == 0 {
+		cfg.ClockSkew = DefaultJWTClockSkew
+	}
+	realm := cfg.Realm
+	if realm == "" {
+		realm = DefaultBearerRealm
+	}
+	challenge := "Bearer realm=" + strconv.Quote(realm)
+	toPrincipal := cfg.Principal
+	if toPrincipal == nil {
+		toPrincipal = defaultPrincipal
+	}
+	v := &jwtVerifier{cfg: cfg}
+
+	return func(c IContext) error {
+		authChallengeKey.Set(c, challenge)
+		auth := c.GetRequest().Header.Get("Authorization")
+		scheme, token, _ := strings.Cut(auth, " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			c.GetResponse().Header().Set("WWW-Authenticate", challenge)
+			return ErrUnauthorized
+		}
+		claims, err := v.verify(strings.TrimSpace(token), time.Now())
+		if err != nil {
+			c.Logger().Debugf("invalid token: %s", err)
+			c.GetResponse().Header().Set("WWW-Authenticate",
+				challenge+`, error="invalid_token", error_description=`+strconv.Quote(err.Error()))
+			return ErrUnauthorized
+		}
+		claimsKey.Set(c, claims)
+		p := toPrincipal(claims)
+		if p == nil {
+			return ErrUnauthorized
+		}
+		if p.Method == "" {
+			p.Method = AuthBearer
+		}
+		c.SetPrincipal(p)
+		return c.Proceed()
+	}
+}
+
+func defaultPrincipal(claims Claims) *Principal {
+	return &Principal{
+		Name:       claims.Subject(),
+		Roles:      claims.Roles(),
+		Scopes:     claims.Scopes(),
+		Attributes: claims,
+	}
+}
+
+type jwtHeader struct {
+	Alg  string          `json:"alg"`
+	Kid  string          `json:"kid"`
+	Crit json.RawMessage `json:"crit"`
+}
+
+type jwtVerifier struct {
+	cfg JWTConfig
+}
+
+// VerifyJWT verifies the token signature and claims, as the JWT filter does, returning its claims
+func VerifyJWT(token string, cfg JWTConfig) (Claims, error) {
+	if cfg.ClockSkew == 0 {
+		cfg.ClockSkew = DefaultJWTClockSkew
+	}
+	v := &jwtVerifier{cfg: cfg}
+	return v.verify(token, time.Now())
+}
+
+func (v *jwtVerifier) verify(token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+	if len(header.Crit) > 0 {
+		return nil, errors.New("unsupported critical header")
+	}
+	if !v.allowed(header.Alg) {
+		return nil, fmt.Errorf("algorithm %q not allowed", header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed signature: %w", err)
+	}
+	key, err := v.cfg.Keys.Key(header.Kid, header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+	if err := v.validate(claims, now); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (v *jwtVerifier) allowed(alg string) bool {
+	if alg == "" || alg == "none" {
+		return false
+	}
+	if len(v.cfg.Algorithms) == 0 {
+		return true
+	}
+	for _, a := range v.cfg.Algorithms {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *jwtVerifier) validate(claims Claims, now time.Time) error {
+	skew := v.cfg.ClockSkew
+	if exp, ok := claims.AsTime("exp"); ok {
+		if now.After(exp.Add(skew)) {
+			return errors.New("token expired")
+		}
+	} else if v.cfg.RequireExpiration {
+		return errors.New("token without expiration")
+	}
+	if nbf, ok := claims.AsTime("nbf"); ok && now.Add(skew).Before(nbf) {
+		return errors.New("token not valid yet")
+	}
+	if v.cfg.Issuer != "" && claims.Issuer() != v.cfg.Issuer {
+		return errors.New("invalid issuer")
+	}
+	if len(v.cfg.Audience) > 0 {
+		for _, aud := range claims.Audience() {
+			for _, expected := range v.cfg.Audience {
+				if aud == expected {
+					return nil
+				}
+			}
+		}
+		return errors.New("invalid audience")
+	}
+	return nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	// keeps large numeric claims precise
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+var errInvalidSignature = errors.New("invalid signature")
+
+func algHash(alg string) (crypto.Hash, bool) {
+	if len(alg) != 5 {
+		return 0, false
+	}
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256, true
+	case "384":
+		return crypto.SHA384, true
+	case "512":
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
+// verifySignature checks the signature, requiring the key type to match the algorithm,
+// so that a public key can never be used as an HMAC secret
+func verifySignature(alg string, key interface{}, input, sig []byte) error {
+	if alg == "EdDSA" {
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not suited for %s", alg)
+		}
+		if !ed25519.Verify(pub, input, sig) {
+			return errInvalidSignature
+		}
+		return nil
+	}
+
+	hash, ok := algHash(alg)
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return fmt.Errorf("key is not suited for %s", alg)
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write(input)
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return errInvalidSignature
+		}
+		return nil
+	}
+
+	h := hash.New()
+	h.Write(input)
+	digest := h.Sum(nil)
+	switch alg[:2] {
+	case "RS", "PS":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key is not suited for %s", alg)
+		}
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return errInvalidSignature
+		}
+		return nil
+	case "ES":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || pub.Curve != curveOf(alg) {
+			return fmt.Errorf("key is not suited for %s", alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return errInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errInvalidSignature
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %q", alg)
+}
+
+func curveOf(alg string) elliptic.Curve {
+	switch alg {
+	case "ES256":
+		return elliptic.P256()
+	case "ES384":
+		return elliptic.P384()
+	case "ES512":
+		return elliptic.P521()
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package maze
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// signJWT creates a token for the tests
+func signJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	header := map[string]interface{}{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	hb, err := json.Marshal(header)
+	require.NoError(t, err)
+	cb, err := json.Marshal(claims)
+	require.NoError(t, err)
+	input := base64.RawURLEncoding.EncodeToString(hb) + "." + base64.RawURLEncoding.EncodeToString(cb)
+
+	var sig []byte
+	if alg == "EdDSA" {
+		sig = ed25519.Sign(key.(ed25519.PrivateKey), []byte(input))
+	} else {
+		hash, _ := algHash(alg)
+		h := hash.New()
+		h.Write([]byte(input))
+		digest := h.Sum(nil)
+		switch alg[:2] {
+		case "HS":
+			mac := hmac.New(hash.New, key.([]byte))
+			mac.Write([]byte(input))
+			sig = mac.Sum(nil)
+		case "RS":
+			sig, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), hash, digest)
+		case "PS":
+			sig, err = rsa.SignPSS(rand.Reader, key.(*rsa.PrivateKey), hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		case "ES":
+			var r, s *big.Int
+			priv := key.(*ecdsa.PrivateKey)
+			r, s, err = ecdsa.Sign(rand.Reader, priv, digest)
+			size := (priv.Curve.Params().BitSize + 7) / 8
+			sig = make([]byte, 2*size)
+			r.FillBytes(sig[:size])
+			s.FillBytes(sig[size:])
+		}
+		require.NoError(t, err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ec384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	cfg := JWTConfig{Keys: StaticKeys{
+		"hs":    secret,
+		"rsa":   &rsaKey.PublicKey,
+		"ec":    &ecKey.PublicKey,
+		"ec384": &ec384Key.PublicKey,
+		"ed":    edPub,
+	}}
+	claims := map[string]interface{}{"sub": "ana"}
+
+	cases := []struct {
+		alg string
+		kid string
+		key interface{}
+	}{
+		{"HS256", "hs", secret},
+		{"HS512", "hs", secret},
+		{"RS256", "rsa", rsaKey},
+		{"PS384", "rsa", rsaKey},
+		{"ES256", "ec", ecKey},
+		{"ES384", "ec384", ec384Key},
+		{"EdDSA", "ed", edKey},
+	}
+	for _, tc := range cases {
+		token := signJWT(t, tc.alg, tc.kid, tc.key, claims)
+		c, err := VerifyJWT(token, cfg)
+		require.NoError(t, err, tc.alg)
+		require.Equal(t, "ana", c.Subject())
+
+		// tampered payload
+		parts := strings.Split(token, ".")
+		forged, _ := json.Marshal(map[string]interface{}{"sub": "admin"})
+		parts[1] = base64.RawURLEncoding.EncodeToString(forged)
+		_, err = VerifyJWT(strings.Join(parts, "."), cfg)
+		require.Error(t, err, tc.alg)
+	}
+
+	// the algorithm must match the key type
+	_, err = VerifyJWT(signJWT(t, "HS256", "rsa", []byte("whatever"), claims), cfg)
+	require.Error(t, err)
+	_, err = VerifyJWT(signJWT(t, "ES384", "ec", ecKey, claims), cfg)
+	require.Error(t, err)
+	// none is never accepted
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"ana"}`)) + "."
+	_, err = VerifyJWT(none, cfg)
+	require.Error(t, err)
+	// restricted algorithms
+	_, err = VerifyJWT(signJWT(t, "HS256", "hs", secret, claims), JWTConfig{Keys: cfg.Keys, Algorithms: []string{"RS256"}})
+	require.Error(t, err)
+}
+
+func TestJWTClaims(t *testing.T) {
+	secret := []byte("secret")
+	cfg := JWTConfig{
+		Keys:      StaticKeys{"": secret},
+		Issuer:    "https://issuer",
+		Audience:  []string{"maze"},
+		ClockSkew: 30 * time.Second,
+	}
+	now := time.Now().Unix()
+	verify := func(claims map[string]interface{}) error {
+		_, err := VerifyJWT(signJWT(t, "HS256", "", secret, claims), cfg)
+		return err
+	}
+
+	require.NoError(t, verify(map[string]interface{}{"iss": "https://issuer", "aud": "maze", "exp": now + 60}))
+	require.NoError(t, verify(map[string]interface{}{"iss": "https://issuer", "aud": []string{"other", "maze"}, "exp": now - 10}))
+	require.Error(t, verify(map[string]interface{}{"iss": "https://issuer", "aud": "maze", "exp": now - 60}))
+	require.NoError(t, verify(map[string]interface{}{"iss": "https://issuer", "aud": "maze", "nbf": now + 10}))
+	require.Error(t, verify(map[string]interface{}{"iss": "https://issuer", "aud": "maze", "nbf": now + 60}))
+	require.Error(t, verify(map[string]interface{}{"iss": "https://other", "aud": "maze"}))
+	require.Error(t, verify(map[string]interface{}{"iss": "https://issuer", "aud": "other"}))
+
+	cfg.RequireExpiration = true
+	require.Error(t, verify(map[string]interface{}{"iss": "https://issuer", "aud": "maze"}))
+}
+
+func TestJWTFilter(t *testing.T) {
+	secret := []byte("secret")
+	m := NewMaze()
+	m.Push("/*", JWT(JWTConfig{Keys: StaticKeys{"k": secret}}))
+	m.GET("/me", func(c IContext) error {
+		p := c.Principal()
+		return c.JSON(http.StatusOK, map[string]interface{}{
+			"name":   p.Name,
+			"method": p.Method,
+			"roles":  p.Roles,
+			"scopes": p.Scopes,
+			"tenant": c.Claims().AsString("tenant"),
+		})
+	})
+
+	get := func(auth string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/me", nil)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	token := signJWT(t, "HS256", "k", secret, map[string]interface{}{
+		"sub":    "ana",
+		"roles":  []string{"admin"},
+		"scope":  "read write",
+		"tenant": "acme",
+	})
+	w := get("Bearer " + token)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"name":"ana","method":"bearer","roles":["admin"],"scopes":["read","write"],"tenant":"acme"}`, w.Body.String())
+
+	w = get("")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Equal(t, `Bearer realm="api"`, w.Header().Get("WWW-Authenticate"))
+
+	w = get("Bearer " + token + "x")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+	require.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+}
+
+func jwksDocument(t *testing.T, keys map[string]*rsa.PrivateKey) []byte {
+	type jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	}
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, k := range keys {
+		doc.Keys = append(doc.Keys, jwk{
+			Kty: "RSA",
+			Kid: kid,
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		})
+	}
+	b, err := json.Marshal(doc)
+	require.NoError(t, err)
+	return b
+}
+
+func TestJWKSFileRotation(t *testing.T) {
+	k1, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	k2, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwksDocument(t, map[string]*rsa.PrivateKey{"k1": k1}), 0o600))
+	jwks, err := NewJWKS(JWKSConfig{Source: path, MinRefresh: time.Millisecond})
+	require.NoError(t, err)
+	cfg := JWTConfig{Keys: jwks}
+
+	_, err = VerifyJWT(signJWT(t, "RS256", "k1", k1, map[string]interface{}{"sub": "ana"}), cfg)
+	require.NoError(t, err)
+	_, err = VerifyJWT(signJWT(t, "RS256", "k2", k2, map[string]interface{}{"sub": "ana"}), cfg)
+	require.Error(t, err)
+
+	// the issuer rotates the keys
+	require.NoError(t, os.WriteFile(path, jwksDocument(t, map[string]*rsa.PrivateKey{"k1": k1, "k2": k2}), 0o600))
+	time.Sleep(2 * time.Millisecond)
+	_, err = VerifyJWT(signJWT(t, "RS256", "k2", k2, map[string]interface{}{"sub": "ana"}), cfg)
+	require.NoError(t, err)
+	// the algorithm of the key is enforced
+	_, err = VerifyJWT(signJWT(t, "PS256", "k2", k2, map[string]interface{}{"sub": "ana"}), cfg)
+	require.Error(t, err)
+}
+
+func TestJWKSURL(t *testing.T) {
+	k1, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(jwksDocument(t, map[string]*rsa.PrivateKey{"k1": k1}))
+	}))
+	defer srv.Close()
+
+	jwks, err := NewJWKS(JWKSConfig{Source: srv.URL})
+	require.NoError(t, err)
+	key, err := jwks.Key("k1", "RS256")
+	require.NoError(t, err)
+	require.Equal(t, k1.N, key.(*rsa.PublicKey).N)
+	// unknown keys don't reload before MinRefresh
+	_, err = jwks.Key("k9", "RS256")
+	require.Error(t, err)
+	require.Equal(t, 1, requests)
+}
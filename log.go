@@ -1,9 +1,25 @@
 package maze
 
-import "github.com/sirupsen/logrus"
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+)
 
 type Tags map[string]interface{}
 
+type Level int
+
+const (
+	DebugLevel Level = iota
+	InfoLevel
+	WarnLevel
+	ErrorLevel
+	FatalLevel
+)
+
 type Logger interface {
 	Debugf(format string, args ...interface{})
 	Infof(format string, args ...interface{})
@@ -12,44 +28,102 @@ type Logger interface {
 	Fatalf(format string, args ...interface{})
 	WithTags(tags Tags) Logger
 	WithError(err error) Logger
+	// Enabled checks if the level is logged, so that expensive log arguments can be skipped
+	Enabled(level Level) bool
+}
+
+var _ Logger = SlogWrap{}
+
+// SlogWrap adapts a log/slog logger. It is the default logger of maze.
+type SlogWrap struct {
+	logger *slog.Logger
 }
 
-type LogrusWrap struct {
-	logger *logrus.Entry
+func NewSlog(logger *slog.Logger) SlogWrap {
+	return SlogWrap{
+		logger: logger,
+	}
 }
 
-func NewLogrus(logger *logrus.Logger) LogrusWrap {
-	return LogrusWrap{
-		logger: logrus.NewEntry(logger),
+func (l SlogWrap) log(level slog.Level, format string, args ...interface{}) {
+	ctx := context.Background()
+	if l.logger.Enabled(ctx, level) {
+		l.logger.Log(ctx, level, fmt.Sprintf(format, args...))
 	}
 }
 
-func (l LogrusWrap) Debugf(format string, args ...interface{}) {
-	l.logger.Debugf(format, args...)
+func (l SlogWrap) Debugf(format string, args ...interface{}) {
+	l.log(slog.LevelDebug, format, args...)
 }
 
-func (l LogrusWrap) Infof(format string, args ...interface{}) {
-	l.logger.Infof(format, args...)
+func (l SlogWrap) Infof(format string, args ...interface{}) {
+	l.log(slog.LevelInfo, format, args...)
 }
 
-func (l LogrusWrap) Warnf(format string, args ...interface{}) {
-	l.logger.Warnf(format, args...)
+func (l SlogWrap) Warnf(format string, args ...interface{}) {
+	l.log(slog.LevelWarn, format, args...)
 }
 
-func (l LogrusWrap) Errorf(format string, args ...interface{}) {
-	l.logger.Errorf(format, args...)
+func (l SlogWrap) Errorf(format string, args ...interface{}) {
+	l.log(slog.LevelError, format, args...)
 }
 
-func (l LogrusWrap) Fatalf(format string, args ...interface{}) {
-	l.logger.Fatalf(format, args...)
+// Fatalf logs at error level and exits, since slog has no fatal level
+func (l SlogWrap) Fatalf(format string, args ...interface{}) {
+	l.log(slog.LevelError, format, args...)
+	os.Exit(1)
 }
 
-func (l LogrusWrap) WithError(err error) Logger {
+func (l SlogWrap) WithError(err error) Logger {
 	return l.WithTags(Tags{"error": err})
 }
 
-func (l LogrusWrap) WithTags(vals Tags) Logger {
-	return LogrusWrap{
-		logger: l.logger.WithFields(logrus.Fields(vals)),
+func (l SlogWrap) WithTags(tags Tags) Logger {
+	return SlogWrap{
+		logger: l.logger.With(TagsToArgs(tags)...),
+	}
+}
+
+func (l SlogWrap) Enabled(level Level) bool {
+	var sl slog.Level
+	switch level {
+	case DebugLevel:
+		sl = slog.LevelDebug
+	case InfoLevel:
+		sl = slog.LevelInfo
+	case WarnLevel:
+		sl = slog.LevelWarn
+	default:
+		sl = slog.LevelError
+	}
+	return l.logger.Enabled(context.Background(), sl)
+}
+
+// TagsToArgs converts tags into alternating keys and values, sorted by key,
+// as expected by loggers like slog or zap.
+func TagsToArgs(tags Tags) []interface{} {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+	args := make([]interface{}, 0, len(tags)*2)
+	for _, k := range keys {
+		args = append(args, k, tags[k])
+	}
+	return args
 }
+
+var _ Logger = NopLogger{}
+
+// NopLogger discards everything. Fatalf still exits.
+type NopLogger struct{}
+
+func (NopLogger) Debugf(format string, args ...interface{}) {}
+func (NopLogger) Infof(format string, args ...interface{})  {}
+func (NopLogger) Warnf(format string, args ...interface{})  {}
+func (NopLogger) Errorf(format string, args ...interface{}) {}
+func (NopLogger) Fatalf(format string, args ...interface{}) { os.Exit(1) }
+func (l NopLogger) WithTags(tags Tags) Logger               { return l }
+func (l NopLogger) WithError(err error) Logger              { return l }
+func (NopLogger) Enabled(level Level) bool                  { return false }
@@ -0,0 +1,119 @@
+package maze
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type logLine struct {
+	level string
+	msg   string
+	tags  Tags
+	// repeated are the tags that were set more than once
+	repeated []string
+}
+
+// testLogger records every logged line
+type testLogger struct {
+	mu       *sync.Mutex
+	lines    *[]logLine
+	tags     Tags
+	repeated []string
+}
+
+func newTestLogger() testLogger {
+	return testLogger{mu: &sync.Mutex{}, lines: &[]logLine{}, tags: Tags{}}
+}
+
+func (l testLogger) log(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.lines = append(*l.lines, logLine{level: level, msg: fmt.Sprintf(format, args...), tags: l.tags, repeated: l.repeated})
+}
+
+func (l testLogger) Lines() []logLine {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]logLine{}, *l.lines...)
+}
+
+func (l testLogger) Debugf(format string, args ...interface{}) { l.log("debug", format, args...) }
+func (l testLogger) Infof(format string, args ...interface{})  { l.log("info", format, args...) }
+func (l testLogger) Warnf(format string, args ...interface{})  { l.log("warn", format, args...) }
+func (l testLogger) Errorf(format string, args ...interface{}) { l.log("error", format, args...) }
+func (l testLogger) Fatalf(format string, args ...interface{}) { l.log("fatal", format, args...) }
+
+func (l testLogger) Enabled(level Level) bool {
+	return true
+}
+
+func (l testLogger) WithError(err error) Logger {
+	return l.WithTags(Tags{"error": err})
+}
+
+func (l testLogger) WithTags(tags Tags) Logger {
+	t := Tags{}
+	for k, v := range l.tags {
+		t[k] = v
+	}
+	repeated := l.repeated
+	for k, v := range tags {
+		if _, ok := t[k]; ok {
+			repeated = append(repeated[:len(repeated):len(repeated)], k)
+		}
+		t[k] = v
+	}
+	return testLogger{mu: l.mu, lines: l.lines, tags: t, repeated: repeated}
+}
+
+type calcService struct{}
+
+func (s *calcService) Double(n int) int {
+	return n * 2
+}
+
+func TestContextLogger(t *testing.T) {
+	logger := newTestLogger()
+	m := NewMaze(WithLogger(logger))
+	m.Push("/*", func(c IContext) error {
+		c.SetLogger(c.Logger().WithTags(Tags{"tenant": "acme"}))
+		return c.Proceed()
+	})
+	rpc, err := NewJsonRpcService(&calcService{})
+	require.NoError(t, err)
+	m.Add(rpc.Build("/calc")...)
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("POST", "/calc/Double", strings.NewReader(`"x"`)))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var found bool
+	for _, l := range logger.Lines() {
+		if l.level == "error" {
+			found = true
+			require.Equal(t, "acme", l.tags["tenant"])
+		}
+	}
+	require.True(t, found)
+}
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlog(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	require.False(t, logger.Enabled(DebugLevel))
+	require.True(t, logger.Enabled(ErrorLevel))
+
+	logger.Debugf("hidden")
+	logger.WithTags(Tags{"b": 2, "a": 1}).Infof("hello %s", "maze")
+	require.NotContains(t, buf.String(), "hidden")
+	require.Contains(t, buf.String(), `msg="hello maze" a=1 b=2`)
+}
@@ -1,18 +1,34 @@
 package maze
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/quintans/toolkit/web"
-	"github.com/sirupsen/logrus"
 )
 
 type ContextFactory func(l Logger, w http.ResponseWriter, r *http.Request, filters []*Filter) IContext
 
+// MazeContextFactory creates the context of each request.
+// The Maze is passed so that its settings (logger, converters, body limits, ...) are available to the context.
+type MazeContextFactory func(m *Maze, w http.ResponseWriter, r *http.Request, filters []*Filter) IContext
+
 type Option func(m *Maze)
 
+// WithContextFactory sets a factory that only gets the logger of the Maze.
+// The contexts created with NewContext use the default converters and body limits, ignoring the other options.
 func WithContextFactory(cf ContextFactory) Option {
+	return func(m *Maze) {
+		m.contextFactory = func(m *Maze, w http.ResponseWriter, r *http.Request, filters []*Filter) IContext {
+			return cf(m.logger, w, r, filters)
+		}
+	}
+}
+
+// WithMazeContextFactory sets a factory that gets the Maze, to create the context with NewContextWithMaze.
+func WithMazeContextFactory(cf MazeContextFactory) Option {
 	return func(m *Maze) {
 		m.contextFactory = cf
 	}
@@ -24,10 +40,44 @@ func WithLogger(l Logger) Option {
 	}
 }
 
+// WithTimeLayouts sets the accepted layouts, in order, when converting path and query parameters to time.Time.
+// They are used by PathVars, QueryVars and Vars, and by Values.AsTime if given IContext.Converters().TimeLayouts().
+func WithTimeLayouts(layouts ...string) Option {
+	return func(m *Maze) {
+		m.converters.SetTimeLayouts(layouts...)
+	}
+}
+
+// WithConverter registers a converter, for the type of value, used when decoding path and query parameters.
+func WithConverter(value interface{}, converter Converter) Option {
+	return func(m *Maze) {
+		m.converters.Register(value, converter)
+	}
+}
+
+// WithBodyLimit sets the default maximum size of request bodies.
+// Zero or less means no limit.
+func WithBodyLimit(limit int64) Option {
+	return func(m *Maze) {
+		m.bodyLimit = limit
+	}
+}
+
+// WithBodyMemoryThreshold sets the size above which a request body, cached with IContext.Body,
+// is kept in a temporary file instead of in memory.
+func WithBodyMemoryThreshold(threshold int64) Option {
+	return func(m *Maze) {
+		m.bodyThreshold = threshold
+	}
+}
+
 // NewMaze creates maze with context factory. If nil, it uses a default context factory
 func NewMaze(options ...Option) *Maze {
 	m := &Maze{
-		logger: NewLogrus(logrus.StandardLogger()),
+		logger:        NewSlog(slog.Default()),
+		converters:    NewConverters(),
+		bodyLimit:     DefaultBodyLimit,
+		bodyThreshold: DefaultBodyMemoryThreshold,
 	}
 	for _, o := range options {
 		o(m)
@@ -37,8 +87,11 @@ func NewMaze(options ...Option) *Maze {
 
 type Maze struct {
 	logger         Logger
+	converters     *Converters
+	bodyLimit      int64
+	bodyThreshold  int64
 	filters        []*Filter
-	contextFactory ContextFactory
+	contextFactory MazeContextFactory
 	lastRule       string
 }
 
@@ -47,17 +100,38 @@ func (m *Maze) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		var ctx IContext
 		if m.contextFactory == nil {
 			// default
-			ctx = NewContext(m.logger, w, r, m.filters)
+			ctx = NewContextWithMaze(m, w, r, m.filters)
 		} else {
-			ctx = m.contextFactory(m.logger, w, r, m.filters)
+			ctx = m.contextFactory(m, w, r, m.filters)
 		}
+		defer ctx.End()
 		err := ctx.Proceed()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			rw := ctx.ResponseWriter()
+			if rw.Committed() {
+				m.logger.Errorf("error after the response for %s was committed with status %d: %s", r.URL.Path, rw.Status(), err)
+				return
+			}
+			var fail *web.HttpFail
+			if errors.As(err, &fail) {
+				http.Error(rw, fail.GetMessage(), fail.Status)
+			} else {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+			}
 		}
 	}
 }
 
+// Logger returns the logger of this maze
+func (m *Maze) Logger() Logger {
+	return m.logger
+}
+
+// Converters returns the converters used when decoding path and query parameters
+func (m *Maze) Converters() *Converters {
+	return m.converters
+}
+
 func (m *Maze) GET(rule string, filters ...Handler) {
 	m.PushMethod([]string{http.MethodGet}, rule, filters...)
 }
@@ -0,0 +1,66 @@
+// Package mazelogrus adapts a logrus logger to the maze Logger
+package mazelogrus
+
+import (
+	"github.com/quintans/maze"
+	"github.com/sirupsen/logrus"
+)
+
+var _ maze.Logger = LogrusWrap{}
+
+type LogrusWrap struct {
+	logger *logrus.Entry
+}
+
+func NewLogrus(logger *logrus.Logger) LogrusWrap {
+	return LogrusWrap{
+		logger: logrus.NewEntry(logger),
+	}
+}
+
+func (l LogrusWrap) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(format, args...)
+}
+
+func (l LogrusWrap) Infof(format string, args ...interface{}) {
+	l.logger.Infof(format, args...)
+}
+
+func (l LogrusWrap) Warnf(format string, args ...interface{}) {
+	l.logger.Warnf(format, args...)
+}
+
+func (l LogrusWrap) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf(format, args...)
+}
+
+func (l LogrusWrap) Fatalf(format string, args ...interface{}) {
+	l.logger.Fatalf(format, args...)
+}
+
+func (l LogrusWrap) WithError(err error) maze.Logger {
+	return l.WithTags(maze.Tags{"error": err})
+}
+
+func (l LogrusWrap) WithTags(vals maze.Tags) maze.Logger {
+	return LogrusWrap{
+		logger: l.logger.WithFields(logrus.Fields(vals)),
+	}
+}
+
+func (l LogrusWrap) Enabled(level maze.Level) bool {
+	var ll logrus.Level
+	switch level {
+	case maze.DebugLevel:
+		ll = logrus.DebugLevel
+	case maze.InfoLevel:
+		ll = logrus.InfoLevel
+	case maze.WarnLevel:
+		ll = logrus.WarnLevel
+	case maze.ErrorLevel:
+		ll = logrus.ErrorLevel
+	default:
+		ll = logrus.FatalLevel
+	}
+	return l.logger.Logger.IsLevelEnabled(ll)
+}
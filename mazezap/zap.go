@@ -0,0 +1,67 @@
+// Package mazezap adapts a zap logger to the maze Logger
+package mazezap
+
+import (
+	"github.com/quintans/maze"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var _ maze.Logger = ZapWrap{}
+
+type ZapWrap struct {
+	logger *zap.SugaredLogger
+}
+
+func NewZap(logger *zap.Logger) ZapWrap {
+	return ZapWrap{
+		logger: logger.Sugar(),
+	}
+}
+
+func (l ZapWrap) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(format, args...)
+}
+
+func (l ZapWrap) Infof(format string, args ...interface{}) {
+	l.logger.Infof(format, args...)
+}
+
+func (l ZapWrap) Warnf(format string, args ...interface{}) {
+	l.logger.Warnf(format, args...)
+}
+
+func (l ZapWrap) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf(format, args...)
+}
+
+func (l ZapWrap) Fatalf(format string, args ...interface{}) {
+	l.logger.Fatalf(format, args...)
+}
+
+func (l ZapWrap) WithError(err error) maze.Logger {
+	return l.WithTags(maze.Tags{"error": err})
+}
+
+func (l ZapWrap) WithTags(tags maze.Tags) maze.Logger {
+	return ZapWrap{
+		logger: l.logger.With(maze.TagsToArgs(tags)...),
+	}
+}
+
+func (l ZapWrap) Enabled(level maze.Level) bool {
+	var zl zapcore.Level
+	switch level {
+	case maze.DebugLevel:
+		zl = zapcore.DebugLevel
+	case maze.InfoLevel:
+		zl = zapcore.InfoLevel
+	case maze.WarnLevel:
+		zl = zapcore.WarnLevel
+	case maze.ErrorLevel:
+		zl = zapcore.ErrorLevel
+	default:
+		zl = zapcore.FatalLevel
+	}
+	return l.logger.Desugar().Core().Enabled(zl)
+}
@@ -0,0 +1,308 @@
+package maze
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultMetricsRule = "/metrics"
+
+// DefaultMetricsBuckets are the upper bounds, in seconds, of the latency histogram buckets
+var DefaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var metricsKey = NewKey[*Metrics]("metrics")
+
+type MetricsConfig struct {
+	// Namespace prefixes every metric name. Default is maze.
+	Namespace string
+	// Rule is where the metrics are exposed. Default is /metrics.
+	Rule string
+	// Buckets are the latency histogram buckets, in seconds. Default is DefaultMetricsBuckets.
+	Buckets []float64
+}
+
+type requestLabels struct {
+	route  string
+	method string
+	status int
+}
+
+type routeLabels struct {
+	route  string
+	method string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type rpcLabels struct {
+	action  string
+	outcome string
+}
+
+// Metrics records request counts, latencies and in-flight requests labelled by route template,
+// exposing them in the Prometheus text format.
+// The route template, and not the path, is used to keep the number of label values bounded.
+// An in-flight request moves to the route of each rule it matches, ending in the route of its handler.
+type Metrics struct {
+	mu        sync.Mutex
+	namespace string
+	rule      string
+	buckets   []float64
+	requests  map[requestLabels]uint64
+	latencies map[routeLabels]*histogram
+	inFlight  map[routeLabels]int64
+	rpcCalls  map[rpcLabels]uint64
+	brokers   map[string]*SseBroker
+}
+
+func NewMetrics(cfg MetricsConfig) *Metrics {
+	m := &Metrics{
+		namespace: cfg.Namespace,
+		rule:      cfg.Rule,
+		buckets:   cfg.Buckets,
+		requests:  map[requestLabels]uint64{},
+		latencies: map[routeLabels]*histogram{},
+		inFlight:  map[routeLabels]int64{},
+		rpcCalls:  map[rpcLabels]uint64{},
+		brokers:   map[string]*SseBroker{},
+	}
+	if m.namespace == "" {
+		m.namespace = "maze"
+	}
+	if m.rule == "" {
+		m.rule = DefaultMetricsRule
+	}
+	if len(m.buckets) == 0 {
+		m.buckets = DefaultMetricsBuckets
+	}
+	return m
+}
+
+// Register adds the metrics filter for every request and the metrics endpoint to the maze.
+// It should be called before registering any other rule.
+func (m *Metrics) Register(mz *Maze) {
+	mz.Push("/*", m.Handle)
+	mz.GET(m.rule, m.Serve)
+}
+
+// RegisterSse exposes the number of subscribers of the broker, labelled with name
+func (m *Metrics) RegisterSse(name string, broker *SseBroker) {
+	m.mu.Lock()
+	m.brokers[name] = broker
+	m.mu.Unlock()
+}
+
+// Handle records the metrics of the request
+func (m *Metrics) Handle(c IContext) error {
+	method := c.GetRequest().Method
+	route := c.Route()
+	m.mu.Lock()
+	m.inFlight[routeLabels{route: route, method: method}]++
+	m.mu.Unlock()
+	// the route of the handler is only known as the next filters match
+	onRoute(c, func(matched string) {
+		m.mu.Lock()
+		m.inFlight[routeLabels{route: route, method: method}]--
+		m.inFlight[routeLabels{route: matched, method: method}]++
+		m.mu.Unlock()
+		route = matched
+	})
+
+	metricsKey.Set(c, m)
+	start := time.Now()
+	var err error
+	completed := false
+	// records even in the case of a panic, so that the request is no longer in flight
+	defer func() {
+		status := http.StatusInternalServerError
+		if completed {
+			status = responseStatus(c, err)
+		}
+		m.record(method, route, status, time.Since(start).Seconds())
+	}()
+	err = c.Proceed()
+	completed = true
+	return err
+}
+
+func (m *Metrics) record(method, route string, status int, elapsed float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ll := routeLabels{route: route, method: method}
+	m.inFlight[ll]--
+	m.requests[requestLabels{route: route, method: method, status: status}]++
+	h := m.latencies[ll]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.latencies[ll] = h
+	}
+	for k, b := range m.buckets {
+		if elapsed <= b {
+			h.counts[k]++
+			break
+		}
+	}
+	h.sum += elapsed
+	h.count++
+}
+
+// recordRpc counts a JSON-RPC call, if the request went through the metrics filter
+func recordRpc(c IContext, err error) {
+	m, ok := metricsKey.Get(c)
+	if !ok {
+		return
+	}
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	m.mu.Lock()
+	m.rpcCalls[rpcLabels{action: c.Route(), outcome: outcome}]++
+	m.mu.Unlock()
+}
+
+// Serve writes the metrics in the Prometheus text exposition format
+func (m *Metrics) Serve(c IContext) error {
+	var buf bytes.Buffer
+	m.write(&buf)
+
+	w := c.GetResponse()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func (m *Metrics) write(buf *bytes.Buffer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := m.namespace + "_http_requests_total"
+	header(buf, name, "counter", "Total number of HTTP requests.")
+	rkeys := make([]requestLabels, 0, len(m.requests))
+	for k := range m.requests {
+		rkeys = append(rkeys, k)
+	}
+	sort.Slice(rkeys, func(i, j int) bool {
+		a, b := rkeys[i], rkeys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	for _, k := range rkeys {
+		sample(buf, name, labels("route", k.route, "method", k.method, "status", strconv.Itoa(k.status)), float64(m.requests[k]))
+	}
+
+	name = m.namespace + "_http_request_duration_seconds"
+	header(buf, name, "histogram", "HTTP request latencies in seconds.")
+	for _, k := range sortedRouteLabels(m.latencies) {
+		h := m.latencies[k]
+		var cumulative uint64
+		for i, b := range m.buckets {
+			cumulative += h.counts[i]
+			sample(buf, name+"_bucket", labels("route", k.route, "method", k.method, "le", formatFloat(b)), float64(cumulative))
+		}
+		sample(buf, name+"_bucket", labels("route", k.route, "method", k.method, "le", "+Inf"), float64(h.count))
+		sample(buf, name+"_sum", labels("route", k.route, "method", k.method), h.sum)
+		sample(buf, name+"_count", labels("route", k.route, "method", k.method), float64(h.count))
+	}
+
+	name = m.namespace + "_http_requests_in_flight"
+	header(buf, name, "gauge", "Number of HTTP requests being handled.")
+	for _, k := range sortedRouteLabels(m.inFlight) {
+		sample(buf, name, labels("route", k.route, "method", k.method), float64(m.inFlight[k]))
+	}
+
+	if len(m.brokers) > 0 {
+		name = m.namespace + "_sse_subscribers"
+		header(buf, name, "gauge", "Number of server sent events subscribers.")
+		for _, k := range sortedKeys(m.brokers) {
+			sample(buf, name, labels("broker", k), float64(m.brokers[k].Subscribers()))
+		}
+	}
+
+	if len(m.rpcCalls) > 0 {
+		name = m.namespace + "_jsonrpc_calls_total"
+		header(buf, name, "counter", "Total number of JSON-RPC calls.")
+		ckeys := make([]rpcLabels, 0, len(m.rpcCalls))
+		for k := range m.rpcCalls {
+			ckeys = append(ckeys, k)
+		}
+		sort.Slice(ckeys, func(i, j int) bool {
+			if ckeys[i].action != ckeys[j].action {
+				return ckeys[i].action < ckeys[j].action
+			}
+			return ckeys[i].outcome < ckeys[j].outcome
+		})
+		for _, k := range ckeys {
+			sample(buf, name, labels("action", k.action, "outcome", k.outcome), float64(m.rpcCalls[k]))
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedRouteLabels[V any](m map[routeLabels]V) []routeLabels {
+	keys := make([]routeLabels, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].method < keys[j].method
+	})
+	return keys
+}
+
+func header(buf *bytes.Buffer, name, kind, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sample(buf *bytes.Buffer, name, labels string, value float64) {
+	fmt.Fprintf(buf, "%s{%s} %s\n", name, labels, formatFloat(value))
+}
+
+// labels formats the label pairs, escaping the values
+func labels(pairs ...string) string {
+	var sb strings.Builder
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(pairs[i])
+		sb.WriteString(`="`)
+		sb.WriteString(labelEscaper.Replace(pairs[i+1]))
+		sb.WriteString(`"`)
+	}
+	return sb.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
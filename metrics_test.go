@@ -0,0 +1,68 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics(MetricsConfig{Buckets: []float64{1, 10}})
+	m := NewMaze()
+	metrics.Register(m)
+	metrics.RegisterSse("news", NewSseBroker())
+	rpc, err := NewJsonRpcService(&calcService{})
+	require.NoError(t, err)
+	m.Add(rpc.Build("/calc")...)
+	m.GET("/greet/:Id", func(c IContext) error {
+		return c.TEXT(http.StatusOK, "hi")
+	})
+
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/greet/1", nil))
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/greet/2", nil))
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/calc/Double", strings.NewReader("2")))
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/calc/Double", strings.NewReader(`"x"`)))
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	for _, line := range []string{
+		"# TYPE maze_http_requests_total counter",
+		`maze_http_requests_total{route="/greet/:Id",method="GET",status="200"} 2`,
+		`maze_http_requests_total{route="/calc/Double",method="POST",status="500"} 1`,
+		`maze_http_request_duration_seconds_bucket{route="/greet/:Id",method="GET",le="1"} 2`,
+		`maze_http_request_duration_seconds_bucket{route="/greet/:Id",method="GET",le="+Inf"} 2`,
+		`maze_http_request_duration_seconds_count{route="/greet/:Id",method="GET"} 2`,
+		// the metrics request itself
+		`maze_http_requests_in_flight{route="/metrics",method="GET"} 1`,
+		`maze_http_requests_in_flight{route="/greet/:Id",method="GET"} 0`,
+		`maze_sse_subscribers{broker="news"} 0`,
+		`maze_jsonrpc_calls_total{action="/calc/Double",outcome="error"} 1`,
+		`maze_jsonrpc_calls_total{action="/calc/Double",outcome="ok"} 1`,
+	} {
+		require.Contains(t, body, line+"\n")
+	}
+}
+
+func TestMetricsPanic(t *testing.T) {
+	metrics := NewMetrics(MetricsConfig{})
+	m := NewMaze()
+	metrics.Register(m)
+	m.GET("/boom", func(c IContext) error {
+		panic("boom")
+	})
+
+	require.Panics(t, func() {
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/boom", nil))
+	})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	require.Contains(t, body, `maze_http_requests_total{route="/boom",method="GET",status="500"} 1`+"\n")
+	require.Contains(t, body, `maze_http_requests_in_flight{route="/boom",method="GET"} 0`+"\n")
+}
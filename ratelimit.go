@@ -0,0 +1,295 @@
+package maze
+
+import (
+	"container/list"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/quintans/toolkit/web"
+)
+
+const (
+	RATE_LIMITED = "MAZE08"
+
+	// DefaultRateLimitMaxKeys is the number of keys tracked by the in-memory store created by RateLimit
+	DefaultRateLimitMaxKeys = 10000
+)
+
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket refills the bucket continuously, allowing bursts up to its capacity
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow weights the count of the previous window by how much it still overlaps the sliding window
+	SlidingWindow
+)
+
+// RateLimitPolicy allows Requests per Window
+type RateLimitPolicy struct {
+	Algorithm RateLimitAlgorithm
+	Requests  int
+	Window    time.Duration
+	// Burst is the capacity of the token bucket. Default is Requests.
+	Burst int
+}
+
+// RateLimitResult is the outcome of consuming one request
+type RateLimitResult struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the quota is fully restored
+	Reset time.Duration
+	// RetryAfter is the time until a request is allowed, if not allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the rate limit state of each key.
+// Shared backends must consume atomically and be safe for concurrent use.
+type RateLimitStore interface {
+	// Allow consumes one request for the key, according to the policy
+	Allow(key string, policy RateLimitPolicy) (RateLimitResult, error)
+}
+
+// KeyFunc returns the key to which the rate limit applies. An empty key falls back to the client IP.
+type KeyFunc func(c IContext) string
+
+// KeyByPrincipal keys by the name of the authenticated principal, so it must come after the authentication filter
+func KeyByPrincipal() KeyFunc {
+	return func(c IContext) string {
+		if p := c.Principal(); p != nil {
+			return "principal:" + p.Name
+		}
+		return ""
+	}
+}
+
+// KeyByIP keys by the client IP
+func KeyByIP(trustProxy bool) KeyFunc {
+	return func(c IContext) string {
+		return ClientIP(c.GetRequest(), trustProxy)
+	}
+}
+
+// KeyByHeader keys by a request header, like an API key
+func KeyByHeader(name string) KeyFunc {
+	return func(c IContext) string {
+		return c.GetRequest().Header.Get(name)
+	}
+}
+
+// KeyByQuery keys by a query parameter, like an API key
+func KeyByQuery(name string) KeyFunc {
+	return func(c IContext) string {
+		return c.GetRequest().URL.Query().Get(name)
+	}
+}
+
+type RateLimitConfig struct {
+	RateLimitPolicy
+	// Key defaults to the client IP
+	Key KeyFunc
+	// TrustProxy is used to get the client IP
+	TrustProxy bool
+	// Store defaults to a MemoryRateLimitStore with DefaultRateLimitMaxKeys
+	Store RateLimitStore
+	// Name prefixes the keys, so that different limits can share the store
+	Name string
+}
+
+// RateLimit limits the rate of requests per key, replying 429 with Retry-After when exceeded.
+// Every response has the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers.
+// If the store fails, the request is allowed.
+func RateLimit(cfg RateLimitConfig) Handler {
+	if cfg.Requests <= 0 || cfg.Window <= 0 {
+		panic("rate limit requires positive Requests and Window")
+	}
+	store := cfg.Store
+	if store == nil {
+		store = NewMemoryRateLimitStore(DefaultRateLimitMaxKeys)
+	}
+	policy := cfg.RateLimitPolicy
+	policyHeader := strconv.Itoa(policy.Requests) + ";w=" + strconv.Itoa(int(math.Ceil(policy.Window.Seconds())))
+	if policy.Algorithm == TokenBucket && policy.Burst > 0 && policy.Burst != policy.Requests {
+		policyHeader += ";burst=" + strconv.Itoa(policy.Burst)
+	}
+
+	return func(c IContext) error {
+		var key string
+		if cfg.Key != nil {
+			key = cfg.Key(c)
+		}
+		if key == "" {
+			key = ClientIP(c.GetRequest(), cfg.TrustProxy)
+		}
+		res, err := store.Allow(cfg.Name+":"+key, policy)
+		if err != nil {
+			c.Logger().Warnf("unable to check the rate limit: %+v", err)
+			return c.Proceed()
+		}
+
+		h := c.GetResponse().Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		h.Set("RateLimit-Policy", policyHeader)
+		if !res.Allowed {
+			h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			return web.NewHttpFail(http.StatusTooManyRequests, RATE_LIMITED, "Too many requests")
+		}
+		return c.Proceed()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+type rateLimitEntry struct {
+	key string
+	// token bucket
+	tokens float64
+	last   time.Time
+	// sliding window
+	windowStart time.Time
+	previous    int
+	current     int
+}
+
+// MemoryRateLimitStore keeps the rate limit state in memory,
+// evicting the least recently used keys when the maximum number of keys is reached
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	maxKeys int
+	order   *list.List
+	entries map[string]*list.Element
+	now     func() time.Time
+}
+
+var _ RateLimitStore = &MemoryRateLimitStore{}
+
+// NewMemoryRateLimitStore creates a store tracking at most maxKeys keys. Zero or less means no limit.
+func NewMemoryRateLimitStore(maxKeys int) *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		maxKeys: maxKeys,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Allow(key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var entry *rateLimitEntry
+	if e, ok := s.entries[key]; ok {
+		s.order.MoveToFront(e)
+		entry = e.Value.(*rateLimitEntry)
+	} else {
+		entry = &rateLimitEntry{
+			key:         key,
+			tokens:      float64(bucketCapacity(policy)),
+			last:        now,
+			windowStart: now,
+		}
+		s.entries[key] = s.order.PushFront(entry)
+		for s.maxKeys > 0 && s.order.Len() > s.maxKeys {
+			last := s.order.Back()
+			s.order.Remove(last)
+			delete(s.entries, last.Value.(*rateLimitEntry).key)
+		}
+	}
+
+	if policy.Algorithm == SlidingWindow {
+		return entry.slidingWindow(now, policy), nil
+	}
+	return entry.tokenBucket(now, policy), nil
+}
+
+// Len returns the number of tracked keys
+func (s *MemoryRateLimitStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func bucketCapacity(policy RateLimitPolicy) int {
+	if policy.Burst > 0 {
+		return policy.Burst
+	}
+	return policy.Requests
+}
+
+func (e *rateLimitEntry) tokenBucket(now time.Time, policy RateLimitPolicy) RateLimitResult {
+	capacity := float64(bucketCapacity(policy))
+	// tokens per second
+	rate := float64(policy.Requests) / policy.Window.Seconds()
+
+	e.tokens = math.Min(capacity, e.tokens+now.Sub(e.last).Seconds()*rate)
+	e.last = now
+
+	res := RateLimitResult{Limit: int(capacity)}
+	if e.tokens >= 1 {
+		e.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - e.tokens) / rate)
+	}
+	res.Remaining = int(e.tokens)
+	res.Reset = seconds((capacity - e.tokens) / rate)
+	return res
+}
+
+func (e *rateLimitEntry) slidingWindow(now time.Time, policy RateLimitPolicy) RateLimitResult {
+	window := policy.Window
+	if elapsed := now.Sub(e.windowStart); elapsed >= window {
+		windows := elapsed / window
+		if windows == 1 {
+			e.previous = e.current
+		} else {
+			e.previous = 0
+		}
+		e.current = 0
+		e.windowStart = e.windowStart.Add(windows * window)
+	}
+	elapsed := now.Sub(e.windowStart)
+	weight := 1 - float64(elapsed)/float64(window)
+	limit := float64(policy.Requests)
+	count := float64(e.previous)*weight + float64(e.current)
+
+	res := RateLimitResult{Limit: policy.Requests}
+	if count+1 <= limit {
+		e.current++
+		count++
+		res.Allowed = true
+	} else if e.current < policy.Requests {
+		// waits for the previous window to slide out enough
+		// previous * (1 - (elapsed + t) / window) + current + 1 <= limit
+		t := (1-(limit-float64(e.current)-1)/float64(e.previous))*float64(window) - float64(elapsed)
+		res.RetryAfter = time.Duration(t)
+	} else {
+		// waits for the next window and for the current one to slide out enough
+		t := float64(window-elapsed) + (1-(limit-1)/float64(e.current))*float64(window)
+		res.RetryAfter = time.Duration(t)
+	}
+	res.Remaining = int(math.Max(0, limit-math.Ceil(count)))
+	if e.current > 0 {
+		// the current window only stops counting at the end of the next one
+		res.Reset = 2*window - elapsed
+	} else if e.previous > 0 {
+		res.Reset = window - elapsed
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,120 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryRateLimitStore(0)
+	s.now = func() time.Time { return now }
+	policy := RateLimitPolicy{Requests: 1, Window: time.Second, Burst: 3}
+
+	for i := 2; i >= 0; i-- {
+		res, err := s.Allow("k", policy)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+		require.Equal(t, i, res.Remaining)
+	}
+	res, _ := s.Allow("k", policy)
+	require.False(t, res.Allowed)
+	require.Equal(t, time.Second, res.RetryAfter)
+	require.Equal(t, 3*time.Second, res.Reset)
+
+	now = now.Add(1500 * time.Millisecond)
+	res, _ = s.Allow("k", policy)
+	require.True(t, res.Allowed)
+	res, _ = s.Allow("k", policy)
+	require.False(t, res.Allowed)
+	require.Equal(t, 500*time.Millisecond, res.RetryAfter)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	now := time.Now()
+	s := NewMemoryRateLimitStore(0)
+	s.now = func() time.Time { return now }
+	policy := RateLimitPolicy{Algorithm: SlidingWindow, Requests: 4, Window: 10 * time.Second}
+
+	for i := 0; i < 4; i++ {
+		res, _ := s.Allow("k", policy)
+		require.True(t, res.Allowed)
+	}
+	res, _ := s.Allow("k", policy)
+	require.False(t, res.Allowed)
+	require.Equal(t, 0, res.Remaining)
+	require.Equal(t, 20*time.Second, res.Reset)
+	// next window plus a quarter of the previous to slide out
+	require.Equal(t, 12500*time.Millisecond, res.RetryAfter)
+
+	// half of the previous window still counts
+	now = now.Add(15 * time.Second)
+	res, _ = s.Allow("k", policy)
+	require.True(t, res.Allowed)
+	res, _ = s.Allow("k", policy)
+	require.True(t, res.Allowed)
+	res, _ = s.Allow("k", policy)
+	require.False(t, res.Allowed)
+	require.Equal(t, 2500*time.Millisecond, res.RetryAfter)
+
+	now = now.Add(30 * time.Second)
+	res, _ = s.Allow("k", policy)
+	require.True(t, res.Allowed)
+	require.Equal(t, 3, res.Remaining)
+}
+
+func TestMemoryRateLimitStoreEviction(t *testing.T) {
+	s := NewMemoryRateLimitStore(2)
+	policy := RateLimitPolicy{Requests: 1, Window: time.Minute}
+	for _, k := range []string{"a", "b", "c"} {
+		res, _ := s.Allow(k, policy)
+		require.True(t, res.Allowed)
+	}
+	require.Equal(t, 2, s.Len())
+	// a was evicted so it starts over
+	res, _ := s.Allow("a", policy)
+	require.True(t, res.Allowed)
+	res, _ = s.Allow("c", policy)
+	require.False(t, res.Allowed)
+}
+
+func TestRateLimit(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", RateLimit(RateLimitConfig{
+		RateLimitPolicy: RateLimitPolicy{Requests: 2, Window: time.Minute},
+		Key:             KeyByHeader("X-Api-Key"),
+	}))
+	m.GET("/items", func(c IContext) error {
+		return c.TEXT(http.StatusOK, "items")
+	})
+
+	get := func(apiKey string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/items", nil)
+		if apiKey != "" {
+			r.Header.Set("X-Api-Key", apiKey)
+		}
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("one")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "30", w.Header().Get("RateLimit-Reset"))
+	require.Equal(t, "2;w=60", w.Header().Get("RateLimit-Policy"))
+	require.Equal(t, http.StatusOK, get("one").Code)
+	w = get("one")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "30", w.Header().Get("Retry-After"))
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	// other keys have their own quota, falling back to the client IP
+	require.Equal(t, http.StatusOK, get("two").Code)
+	require.Equal(t, http.StatusOK, get("").Code)
+}
@@ -0,0 +1,63 @@
+package maze
+
+import "context"
+
+const (
+	DefaultRequestIDHeader = "X-Request-Id"
+	// maxRequestIDLen limits the size of incoming request IDs
+	maxRequestIDLen = 128
+)
+
+type RequestIDConfig struct {
+	// Header is the header with the request ID. Default is X-Request-Id.
+	Header string
+	// Generator generates the request ID when missing. Default is a random UUID.
+	Generator func() string
+}
+
+// RequestID reads the request ID from the request header, generating one when missing or invalid,
+// and echoes it in the response header.
+// The request ID is available through IContext.RequestID and is a tag of the request logger.
+func RequestID(cfg RequestIDConfig) Handler {
+	header := cfg.Header
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+	generate := cfg.Generator
+	if generate == nil {
+		generate = func() string {
+			return NewUUID().String()
+		}
+	}
+
+	return func(c IContext) error {
+		id := c.GetRequest().Header.Get(header)
+		if !validRequestID(id) {
+			id = generate()
+		}
+		c.SetRequestID(id)
+		c.GetResponse().Header().Set(header, id)
+		return c.Proceed()
+	}
+}
+
+// validRequestID accepts only reasonably sized and printable ASCII ids, preventing log injection
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+var requestIDKey = NewKey[string]("request_id")
+
+// RequestIDFrom returns the request ID from the context.Context of a request handled by the RequestID filter
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := requestIDKey.From(ctx)
+	return id
+}
@@ -0,0 +1,38 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestID(t *testing.T) {
+	logger := newTestLogger()
+	m := NewMaze(WithLogger(logger))
+	m.Push("/*", RequestID(RequestIDConfig{}))
+	m.GET("/*", func(c IContext) error {
+		require.Equal(t, c.RequestID(), RequestIDFrom(c.GetRequest().Context()))
+		return c.TEXT(http.StatusOK, c.RequestID())
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set(DefaultRequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, "abc-123", w.Body.String())
+	require.Equal(t, "abc-123", w.Header().Get(DefaultRequestIDHeader))
+	lines := logger.Lines()
+	require.Equal(t, "abc-123", lines[len(lines)-1].tags["request_id"])
+
+	for _, id := range []string{"", "bad\nid"} {
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(DefaultRequestIDHeader, id)
+		w = httptest.NewRecorder()
+		m.ServeHTTP(w, r)
+		_, err := ParseUUID(w.Body.String())
+		require.NoError(t, err)
+		require.Equal(t, w.Body.String(), w.Header().Get(DefaultRequestIDHeader))
+	}
+}
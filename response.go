@@ -0,0 +1,153 @@
+package maze
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/quintans/toolkit/web"
+)
+
+var (
+	_ http.Flusher  = &ResponseWriter{}
+	_ http.Hijacker = &ResponseWriter{}
+	_ http.Pusher   = &ResponseWriter{}
+	_ io.ReaderFrom = &ResponseWriter{}
+)
+
+// ResponseWriter wraps the http.ResponseWriter of a request,
+// recording the status code, the number of bytes written and when the response was committed.
+// The optional interfaces http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom are passed through.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status     int
+	size       int64
+	firstWrite time.Time
+	hijacked   bool
+}
+
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+// Status returns the status code sent, or zero if nothing was sent yet
+func (w *ResponseWriter) Status() int {
+	return w.status
+}
+
+// Size returns the number of body bytes written
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+// FirstWrite returns when the response was committed
+func (w *ResponseWriter) FirstWrite() time.Time {
+	return w.firstWrite
+}
+
+// Committed returns true if the status code was already sent,
+// meaning that neither the status code nor the headers can be changed.
+func (w *ResponseWriter) Committed() bool {
+	return w.status != 0 || w.hijacked
+}
+
+// Unwrap returns the wrapped http.ResponseWriter. Used by http.ResponseController.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *ResponseWriter) WriteHeader(code int) {
+	if w.Committed() {
+		return
+	}
+	// informational responses, except 101, do not commit the response
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+	w.firstWrite = time.Now()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if !w.Committed() {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.Committed() {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the response writer does not support hijacking")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.hijacked = true
+		if w.firstWrite.IsZero() {
+			w.firstWrite = time.Now()
+		}
+	}
+	return conn, rw, err
+}
+
+func (w *ResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.Committed() {
+		w.WriteHeader(http.StatusOK)
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(writerOnly{w.ResponseWriter}, r)
+	}
+	w.size += n
+	return n, err
+}
+
+// responseStatus returns the status of the response of a request that was handled.
+// err is the error returned by the filters.
+func responseStatus(c IContext, err error) int {
+	rw := c.ResponseWriter()
+	if err != nil && !rw.Committed() {
+		// maze will reply with an error
+		var fail *web.HttpFail
+		if errors.As(err, &fail) {
+			return fail.Status
+		}
+		return http.StatusInternalServerError
+	}
+	if rw.Status() == 0 {
+		// net/http replies OK if nothing was written
+		return http.StatusOK
+	}
+	return rw.Status()
+}
+
+// writerOnly hides any io.ReaderFrom implementation, avoiding recursion in io.Copy
+type writerOnly struct {
+	io.Writer
+}
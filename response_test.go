@@ -0,0 +1,48 @@
+package maze
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseWriter(t *testing.T) {
+	var status int
+	var size int64
+	m := NewMaze()
+	m.GET("/ok", func(c IContext) error {
+		err := c.Proceed()
+		rw := c.ResponseWriter()
+		require.True(t, rw.Committed())
+		require.False(t, rw.FirstWrite().IsZero())
+		status, size = rw.Status(), rw.Size()
+		return err
+	}, ResponseBuffer, func(c IContext) error {
+		// buffered, so nothing was sent yet
+		require.False(t, c.ResponseWriter().Committed())
+		_, ok := c.ResponseWriter().ResponseWriter.(http.Flusher)
+		require.True(t, ok)
+		return c.TEXT(http.StatusCreated, "created")
+	})
+	m.GET("/fail", func(c IContext) error {
+		if err := c.TEXT(http.StatusOK, "ok"); err != nil {
+			return err
+		}
+		return errors.New("ignored after commit")
+	})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/ok", nil))
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "created", w.Body.String())
+	require.Equal(t, http.StatusCreated, status)
+	require.Equal(t, int64(7), size)
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/fail", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "ok", w.Body.String())
+}
@@ -0,0 +1,140 @@
+package maze
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// OmitHeader disables a header of SecurityHeadersConfig that would otherwise get its default value
+	OmitHeader = "-"
+	// NoncePlaceholder is replaced, in the Content-Security-Policy, by the nonce of the request
+	NoncePlaceholder = "{nonce}"
+
+	DefaultHSTSMaxAge              = 365 * 24 * time.Hour
+	DefaultContentSecurityPolicy   = "default-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'"
+	DefaultFrameOptions            = "DENY"
+	DefaultReferrerPolicy          = "strict-origin-when-cross-origin"
+	DefaultCrossOriginOpenerPolicy = "same-origin"
+)
+
+var cspNonceKey = NewKey[string]("csp.nonce")
+
+type SecurityHeadersConfig struct {
+	// HSTSMaxAge is the max-age of Strict-Transport-Security, only sent over https.
+	// Default is DefaultHSTSMaxAge and a negative value omits the header.
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	// TrustProxy considers the X-Forwarded-Proto header to decide if the request is over https
+	TrustProxy bool
+	// ContentSecurityPolicy defaults to DefaultContentSecurityPolicy.
+	// Any NoncePlaceholder is replaced by a random nonce, generated per request and available through IContext.CSPNonce,
+	// eg: script-src 'self' 'nonce-{nonce}'
+	ContentSecurityPolicy string
+	// CSPReportOnly sends the policy in Content-Security-Policy-Report-Only, so that it is reported but not enforced
+	CSPReportOnly bool
+	// FrameOptions is the X-Frame-Options value. Default is DefaultFrameOptions.
+	FrameOptions string
+	// ReferrerPolicy defaults to DefaultReferrerPolicy
+	ReferrerPolicy string
+	// CrossOriginOpenerPolicy defaults to DefaultCrossOriginOpenerPolicy
+	CrossOriginOpenerPolicy string
+	// PermissionsPolicy is not sent by default, eg: camera=(), geolocation=()
+	PermissionsPolicy string
+}
+
+// SecurityHeaders sets security related response headers, with defaults for the options not set.
+// X-Content-Type-Options: nosniff is always sent. String options equal to OmitHeader are not sent.
+// Headers are set before proceeding, so a SecurityHeaders filter chained to a route
+// overrides the values of a global one, reusing the request nonce if it was already generated.
+func SecurityHeaders(cfg SecurityHeadersConfig) Handler {
+	var hsts string
+	maxAge := cfg.HSTSMaxAge
+	if maxAge == 0 {
+		maxAge = DefaultHSTSMaxAge
+	}
+	if maxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	csp := headerValue(cfg.ContentSecurityPolicy, DefaultContentSecurityPolicy)
+	cspHeader := "Content-Security-Policy"
+	if cfg.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	withNonce := strings.Contains(csp, NoncePlaceholder)
+	static := [][2]string{
+		{"X-Content-Type-Options", "nosniff"},
+		{"X-Frame-Options", headerValue(cfg.FrameOptions, DefaultFrameOptions)},
+		{"Referrer-Policy", headerValue(cfg.ReferrerPolicy, DefaultReferrerPolicy)},
+		{"Cross-Origin-Opener-Policy", headerValue(cfg.CrossOriginOpenerPolicy, DefaultCrossOriginOpenerPolicy)},
+		{"Permissions-Policy", headerValue(cfg.PermissionsPolicy, "")},
+	}
+
+	return func(c IContext) error {
+		h := c.GetResponse().Header()
+		for _, kv := range static {
+			if kv[1] != "" {
+				h.Set(kv[0], kv[1])
+			} else {
+				h.Del(kv[0])
+			}
+		}
+		if hsts != "" && isHTTPS(c.GetRequest(), cfg.TrustProxy) {
+			h.Set("Strict-Transport-Security", hsts)
+		} else {
+			h.Del("Strict-Transport-Security")
+		}
+		h.Del("Content-Security-Policy")
+		h.Del("Content-Security-Policy-Report-Only")
+		if csp != "" {
+			policy := csp
+			if withNonce {
+				nonce := c.CSPNonce()
+				if nonce == "" {
+					nonce = newNonce()
+					cspNonceKey.Set(c, nonce)
+				}
+				policy = strings.ReplaceAll(policy, NoncePlaceholder, nonce)
+			}
+			h.Set(cspHeader, policy)
+		}
+		return c.Proceed()
+	}
+}
+
+func headerValue(value, def string) string {
+	switch value {
+	case "":
+		return def
+	case OmitHeader:
+		return ""
+	default:
+		return value
+	}
+}
+
+func isHTTPS(r *http.Request, trustProxy bool) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return trustProxy && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func newNonce() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b[:])
+}
@@ -0,0 +1,82 @@
+package maze
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	var nonce string
+	m := NewMaze()
+	m.Push("/*", SecurityHeaders(SecurityHeadersConfig{
+		ContentSecurityPolicy: "script-src 'self' 'nonce-{nonce}'",
+		HSTSIncludeSubdomains: true,
+	}))
+	m.GET("/page", func(c IContext) error {
+		nonce = c.CSPNonce()
+		return c.TEXT(http.StatusOK, "page")
+	})
+	m.GET("/embed", SecurityHeaders(SecurityHeadersConfig{
+		ContentSecurityPolicy: "script-src 'nonce-{nonce}'; frame-ancestors 'self'",
+		FrameOptions:          "SAMEORIGIN",
+		ReferrerPolicy:        OmitHeader,
+		HSTSMaxAge:            -1,
+	}), func(c IContext) error {
+		nonce = c.CSPNonce()
+		return c.TEXT(http.StatusOK, "embed")
+	})
+
+	r := httptest.NewRequest("GET", "/page", nil)
+	r.TLS = &tls.ConnectionState{}
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	h := w.Header()
+	require.NotEmpty(t, nonce)
+	require.Equal(t, "script-src 'self' 'nonce-"+nonce+"'", h.Get("Content-Security-Policy"))
+	require.Equal(t, "max-age=31536000; includeSubDomains", h.Get("Strict-Transport-Security"))
+	require.Equal(t, "nosniff", h.Get("X-Content-Type-Options"))
+	require.Equal(t, DefaultFrameOptions, h.Get("X-Frame-Options"))
+	require.Equal(t, DefaultReferrerPolicy, h.Get("Referrer-Policy"))
+	require.Equal(t, DefaultCrossOriginOpenerPolicy, h.Get("Cross-Origin-Opener-Policy"))
+	require.Empty(t, h.Get("Permissions-Policy"))
+
+	// the nonce changes in every request
+	previous := nonce
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/page", nil))
+	require.NotEqual(t, previous, nonce)
+	// not over https
+	require.Empty(t, w.Header().Get("Strict-Transport-Security"))
+
+	// route overrides
+	r = httptest.NewRequest("GET", "/embed", nil)
+	r.TLS = &tls.ConnectionState{}
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	h = w.Header()
+	require.Equal(t, "script-src 'nonce-"+nonce+"'; frame-ancestors 'self'", h.Get("Content-Security-Policy"))
+	require.Equal(t, "SAMEORIGIN", h.Get("X-Frame-Options"))
+	require.Empty(t, h.Get("Referrer-Policy"))
+	require.Empty(t, h.Get("Strict-Transport-Security"))
+}
+
+func TestSecurityHeadersDefaults(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", SecurityHeaders(SecurityHeadersConfig{TrustProxy: true, CSPReportOnly: true}))
+	m.GET("/page", func(c IContext) error {
+		require.Empty(t, c.CSPNonce())
+		return c.TEXT(http.StatusOK, "page")
+	})
+
+	r := httptest.NewRequest("GET", "/page", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+	require.Equal(t, DefaultContentSecurityPolicy, w.Header().Get("Content-Security-Policy-Report-Only"))
+	require.Empty(t, w.Header().Get("Content-Security-Policy"))
+	require.Equal(t, "max-age=31536000", w.Header().Get("Strict-Transport-Security"))
+}
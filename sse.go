@@ -3,13 +3,27 @@ package maze
 import (
 	"bytes"
 	"errors"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 )
 
 const eol = "\n"
 
+// acceptsEventStream tells if the request asks for server-sent events
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// isEventStream tells if the response is server-sent events.
+// Filters that buffer the response must stream it instead, as they must when the response is flushed.
+func isEventStream(h http.Header) bool {
+	mt, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	return err == nil && mt == "text/event-stream"
+}
+
 // Sse is a server sent event
 type Sse struct {
 	Id    string
@@ -43,6 +57,13 @@ func (s *SseBroker) HasSubscribers() bool {
 	return len(s.subscribers) > 0
 }
 
+// Subscribers returns the number of subscribers
+func (s *SseBroker) Subscribers() int {
+	s.RLock()
+	defer s.RUnlock()
+	return len(s.subscribers)
+}
+
 func (s *SseBroker) subscribe(c chan []byte) {
 	s.Lock()
 	s.subscribers[c] = true
@@ -95,6 +116,8 @@ func (s *SseBroker) Send(e Sse) {
 	s.Unlock()
 }
 
+// Serve subscribes the client to the events until the request is done.
+// Cross-origin clients require the CORS filter in front of it.
 func (s *SseBroker) Serve(c IContext) error {
 	w := c.GetResponse()
 	f, ok := w.(http.Flusher)
@@ -105,7 +128,6 @@ func (s *SseBroker) Serve(c IContext) error {
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Expires", "-1")
 
 	sub := make(chan []byte, 1)
@@ -0,0 +1,254 @@
+package maze
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const DefaultTimeoutMessage = "Request timeout"
+
+type TimeoutConfig struct {
+	// Timeout is the maximum duration of the next filters
+	Timeout time.Duration
+	// Status is the response status when the timeout is reached. Default is 503, but 504 may suit gateways better.
+	Status int
+	// Message is the response body when the timeout is reached. Default is DefaultTimeoutMessage.
+	Message string
+}
+
+// Timeout sets a deadline in the request context.Context, for the next filters to honour,
+// and replies with the configured status if they don't finish in time.
+// The response of the next filters is buffered and only written if they finish in time,
+// so the timeout reply never races with them. Writes after the timeout fail with http.ErrHandlerTimeout.
+// Server-sent events and flushed responses are exempted, as soon as they are detected,
+// and then the context is no longer cancelled at the deadline.
+func Timeout(cfg TimeoutConfig) Handler {
+	if cfg.Timeout <= 0 {
+		panic("timeout requires a positive Timeout")
+	}
+	status := cfg.Status
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	message := cfg.Message
+	if message == "" {
+		message = DefaultTimeoutMessage
+	}
+
+	return func(c IContext) error {
+		r := c.GetRequest()
+		if acceptsEventStream(r) {
+			return c.Proceed()
+		}
+
+		ctx := newTimeoutContext(r.Context(), cfg.Timeout)
+		defer ctx.cancel()
+		w := c.GetResponse()
+		tw := &timeoutWriter{
+			w:      w,
+			header: w.Header().Clone(),
+		}
+		c.SetRequest(r.WithContext(ctx))
+		c.SetResponse(tw)
+		// restores the original request and response, even in the case of a panic
+		defer func() {
+			c.SetRequest(r)
+			c.SetResponse(w)
+		}()
+
+		timer := time.AfterFunc(cfg.Timeout, func() {
+			if tw.timeout(status, message) {
+				ctx.expire()
+			}
+		})
+		defer timer.Stop()
+
+		err := c.Proceed()
+		if ctx.Err() == context.DeadlineExceeded {
+			// the deadline of the parent context may be reached first
+			tw.timeout(status, message)
+		}
+		if tw.finish() {
+			c.Logger().Warnf("request %s %s timed out after %s", r.Method, r.URL.Path, cfg.Timeout)
+			// the timeout reply was already sent
+			return nil
+		}
+		return err
+	}
+}
+
+// timeoutContext is only cancelled at the deadline if the response was not streamed
+type timeoutContext struct {
+	context.Context
+	cancel   context.CancelFunc
+	deadline time.Time
+	mu       sync.Mutex
+	err      error
+}
+
+func newTimeoutContext(parent context.Context, timeout time.Duration) *timeoutContext {
+	ctx, cancel := context.WithCancel(parent)
+	deadline := time.Now().Add(timeout)
+	if d, ok := parent.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return &timeoutContext{Context: ctx, cancel: cancel, deadline: deadline}
+}
+
+func (c *timeoutContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *timeoutContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	return c.Context.Err()
+}
+
+// expire cancels the context with context.DeadlineExceeded
+func (c *timeoutContext) expire() {
+	c.mu.Lock()
+	c.err = context.DeadlineExceeded
+	c.mu.Unlock()
+	c.cancel()
+}
+
+// timeoutWriter buffers the response until the next filters finish or the timeout is reached,
+// unless it turns out to be a stream
+type timeoutWriter struct {
+	mu        sync.Mutex
+	w         http.ResponseWriter
+	header    http.Header
+	buf       bytes.Buffer
+	code      int
+	done      bool
+	timedOut  bool
+	streaming bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.streaming {
+		tw.w.WriteHeader(code)
+		return
+	}
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+	if isEventStream(tw.header) {
+		tw.stream()
+	}
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.streaming {
+		if tw.code == 0 {
+			tw.code = http.StatusOK
+		}
+		if !isEventStream(tw.header) {
+			return tw.buf.Write(b)
+		}
+		tw.stream()
+	}
+	return tw.w.Write(b)
+}
+
+// Flush streams the response, exempting it from the timeout, unless the timeout was already reached
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if !tw.streaming {
+		tw.stream()
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// stream sends what was buffered and writes through from now on
+func (tw *timeoutWriter) stream() {
+	tw.streaming = true
+	tw.writeHeader()
+	if tw.buf.Len() > 0 {
+		tw.w.Write(tw.buf.Bytes())
+		tw.buf.Reset()
+	}
+}
+
+// timeout replies with the status, unless the next filters already finished or are streaming,
+// returning true if it did
+func (tw *timeoutWriter) timeout(status int, message string) bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.done || tw.timedOut || tw.streaming {
+		return false
+	}
+	tw.timedOut = true
+	h := tw.w.Header()
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(len(message)+1))
+	h.Set("X-Content-Type-Options", "nosniff")
+	tw.w.WriteHeader(status)
+	tw.w.Write([]byte(message + "\n"))
+	if f, ok := tw.w.(http.Flusher); ok {
+		// the client gets the reply even if the next filters never return
+		f.Flush()
+	}
+	return true
+}
+
+// finish writes the buffered response, returning true if the timeout was reached instead
+func (tw *timeoutWriter) finish() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.done = true
+	if tw.timedOut {
+		return true
+	}
+	if tw.streaming {
+		return false
+	}
+	tw.writeHeader()
+	if tw.buf.Len() > 0 {
+		tw.w.Write(tw.buf.Bytes())
+	}
+	return false
+}
+
+// writeHeader replaces the headers of the response with the buffered ones and sends the status
+func (tw *timeoutWriter) writeHeader() {
+	dst := tw.w.Header()
+	for k := range dst {
+		if _, ok := tw.header[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	tw.header = dst
+	if tw.code != 0 {
+		tw.w.WriteHeader(tw.code)
+	}
+}
@@ -0,0 +1,100 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeout(t *testing.T) {
+	writeErr := make(chan error, 1)
+	m := NewMaze()
+	m.Push("/*", Timeout(TimeoutConfig{Timeout: 20 * time.Millisecond, Status: http.StatusGatewayTimeout}))
+	m.GET("/fast", func(c IContext) error {
+		_, ok := c.GetRequest().Context().Deadline()
+		require.True(t, ok)
+		c.GetResponse().Header().Set("X-Fast", "yes")
+		return c.TEXT(http.StatusCreated, "fast")
+	})
+	m.GET("/slow", func(c IContext) error {
+		<-c.GetRequest().Context().Done()
+		// waits for the timeout reply
+		time.Sleep(10 * time.Millisecond)
+		_, err := c.GetResponse().Write([]byte("late"))
+		writeErr <- err
+		return c.GetRequest().Context().Err()
+	})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "fast", w.Body.String())
+	require.Equal(t, "yes", w.Header().Get("X-Fast"))
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	require.Equal(t, http.StatusGatewayTimeout, w.Code)
+	require.Equal(t, DefaultTimeoutMessage+"\n", w.Body.String())
+	require.ErrorIs(t, <-writeErr, http.ErrHandlerTimeout)
+}
+
+func TestTimeoutContextError(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", Timeout(TimeoutConfig{Timeout: time.Millisecond}))
+	m.GET("/slow", func(c IContext) error {
+		// returns as soon as the deadline is reached, racing with the timer
+		ctx := c.GetRequest().Context()
+		for ctx.Err() == nil {
+			runtime.Gosched()
+		}
+		return ctx.Err()
+	})
+
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		m.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+		require.Equal(t, DefaultTimeoutMessage+"\n", w.Body.String())
+	}
+}
+
+func TestTimeoutExemptsSse(t *testing.T) {
+	m := NewMaze()
+	m.Push("/*", Timeout(TimeoutConfig{Timeout: 10 * time.Millisecond}))
+	broker := NewSseBroker()
+	broker.OnConnect = func() (Sse, error) {
+		return NewSse("hello"), nil
+	}
+	m.GET("/events", broker.Serve)
+	m.GET("/stream", func(c IContext) error {
+		w := c.GetResponse()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		// outlives the timeout
+		time.Sleep(30 * time.Millisecond)
+		if err := c.GetRequest().Context().Err(); err != nil {
+			return err
+		}
+		w.Write([]byte("data: second\n\n"))
+		w.(http.Flusher).Flush()
+		return nil
+	})
+
+	// detected by the request and by the response
+	for _, accept := range []string{"text/event-stream", ""} {
+		r := httptest.NewRequest("GET", "/events", nil)
+		r.Header.Set("Accept", accept)
+		w := serveStream(m, r)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), "data: hello")
+	}
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/stream", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "data: first\n\ndata: second\n\n", w.Body.String())
+}
@@ -0,0 +1,399 @@
+package maze
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+type TraceID [16]byte
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (t TraceID) IsZero() bool {
+	return t == TraceID{}
+}
+
+type SpanID [8]byte
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s SpanID) IsZero() bool {
+	return s == SpanID{}
+}
+
+// SpanContext is the part of a span that is propagated, as defined by W3C Trace Context
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+}
+
+// ParseTraceparent parses a traceparent header, eg: 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	// future versions may append fields, after a dash
+	if len(s) < 55 || (len(s) > 55 && s[55] != '-') {
+		return sc, errors.New("invalid traceparent length")
+	}
+	if s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, errors.New("invalid traceparent format")
+	}
+	version, err := hex.DecodeString(s[:2])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(s) != 55) {
+		return sc, errors.New("invalid traceparent version")
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(s[3:35])); err != nil || sc.TraceID.IsZero() {
+		return sc, errors.New("invalid trace id")
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(s[36:52])); err != nil || sc.SpanID.IsZero() {
+		return sc, errors.New("invalid parent id")
+	}
+	flags, err := hex.DecodeString(s[53:55])
+	if err != nil {
+		return sc, errors.New("invalid trace flags")
+	}
+	sc.Sampled = flags[0]&1 == 1
+	return sc, nil
+}
+
+// Traceparent formats the span context as a traceparent header
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// Inject sets the trace context headers, to propagate the trace to outgoing requests
+func (sc SpanContext) Inject(h http.Header) {
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	}
+}
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+)
+
+// Span is a timed operation of a trace
+type Span struct {
+	Name       string
+	Kind       SpanKind
+	Context    SpanContext
+	ParentID   SpanID
+	Start      time.Time
+	End        time.Time
+	Attributes Tags
+	// Err is set if the operation failed
+	Err error
+}
+
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s.Attributes == nil {
+		s.Attributes = Tags{}
+	}
+	s.Attributes[key] = value
+}
+
+// SpanExporter receives every ended and sampled span
+type SpanExporter interface {
+	ExportSpan(span *Span) error
+}
+
+type TracingConfig struct {
+	// Exporter receives the spans. Required.
+	Exporter SpanExporter
+	// FilterSpans creates a span for each executed filter, child of the span of the previous filter.
+	FilterSpans bool
+}
+
+// requestTrace holds the tracing state of a request
+type requestTrace struct {
+	exporter    SpanExporter
+	filterSpans bool
+	current     *Span
+}
+
+var traceKey = NewKey[*requestTrace]("trace")
+
+// CurrentSpan returns the current span of a request handled by the Tracing filter, or nil
+func CurrentSpan(c IContext) *Span {
+	if t, ok := traceKey.Get(c); ok {
+		return t.current
+	}
+	return nil
+}
+
+// SpanFrom returns the current span from the context.Context of a request handled by the Tracing filter, or nil.
+// Its context can be injected in outgoing requests to propagate the trace.
+func SpanFrom(ctx context.Context) *Span {
+	if t, ok := traceKey.From(ctx); ok {
+		return t.current
+	}
+	return nil
+}
+
+// Tracing creates a span for each request, continuing the trace from the traceparent and tracestate headers.
+// It should be the first filter so that the span covers every other filter.
+func Tracing(cfg TracingConfig) Handler {
+	if cfg.Exporter == nil {
+		panic("tracing requires an Exporter")
+	}
+
+	return func(c IContext) error {
+		r := c.GetRequest()
+		parent, err := ParseTraceparent(r.Header.Get(TraceparentHeader))
+		if err == nil {
+			parent.TraceState = r.Header.Get(TracestateHeader)
+		} else {
+			parent = SpanContext{TraceID: newTraceID(), Sampled: true}
+		}
+
+		t := &requestTrace{
+			exporter:    cfg.Exporter,
+			filterSpans: cfg.FilterSpans,
+		}
+		span := t.start(r.Method, SpanKindServer, parent)
+		traceKey.Set(c, t)
+
+		err = c.Proceed()
+
+		route := c.Route()
+		status := responseStatus(c, err)
+		span.Name = r.Method + " " + route
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", r.URL.Path)
+		span.SetAttribute("http.status_code", status)
+		if err == nil && status >= http.StatusInternalServerError {
+			span.Err = errors.New(http.StatusText(status))
+		}
+		t.end(c, span, err)
+		return err
+	}
+}
+
+// start starts a span as the current one
+func (t *requestTrace) start(name string, kind SpanKind, parent SpanContext) *Span {
+	span := &Span{
+		Name: name,
+		Kind: kind,
+		Context: SpanContext{
+			TraceID:    parent.TraceID,
+			SpanID:     newSpanID(),
+			Sampled:    parent.Sampled,
+			TraceState: parent.TraceState,
+		},
+		ParentID: parent.SpanID,
+		Start:    time.Now(),
+	}
+	t.current = span
+	return span
+}
+
+// end ends the span, exporting it if sampled
+func (t *requestTrace) end(c IContext, span *Span, err error) {
+	span.End = time.Now()
+	if err != nil {
+		span.Err = err
+	}
+	if span.Context.Sampled {
+		if err := t.exporter.ExportSpan(span); err != nil {
+			c.Logger().WithError(err).Warnf("unable to export span %s", span.Name)
+		}
+	}
+}
+
+// traceFilter executes the filter handler inside a span
+func (t *requestTrace) traceFilter(c IContext, name string, handler Handler) error {
+	parent := t.current
+	span := t.start(name, SpanKindInternal, parent.Context)
+	err := handler(c)
+	t.end(c, span, err)
+	t.current = parent
+	return err
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id.IsZero() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id.IsZero() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+// InMemoryExporter keeps the spans in memory. Useful for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) ExportSpan(span *Span) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, span)
+	e.mu.Unlock()
+	return nil
+}
+
+// Spans returns the exported spans, in the order they ended
+func (e *InMemoryExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span{}, e.spans...)
+}
+
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
+
+// OTLPFileExporter appends each span to a file, as a line in the OTLP/JSON format
+type OTLPFileExporter struct {
+	mu          sync.Mutex
+	file        *os.File
+	serviceName string
+}
+
+func NewOTLPFileExporter(path string, serviceName string) (*OTLPFileExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &OTLPFileExporter{file: f, serviceName: serviceName}, nil
+}
+
+func (e *OTLPFileExporter) ExportSpan(span *Span) error {
+	b, err := json.Marshal(otlpRequest(e.serviceName, span))
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err = e.file.Write(b)
+	return err
+}
+
+func (e *OTLPFileExporter) Close() error {
+	return e.file.Close()
+}
+
+type otlpValue map[string]interface{}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	TraceState        string          `json:"traceState,omitempty"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+// otlpRequest builds an OTLP ExportTraceServiceRequest with a single span
+func otlpRequest(serviceName string, span *Span) interface{} {
+	s := otlpSpan{
+		TraceID:           span.Context.TraceID.String(),
+		SpanID:            span.Context.SpanID.String(),
+		TraceState:        span.Context.TraceState,
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+	}
+	if !span.ParentID.IsZero() {
+		s.ParentSpanID = span.ParentID.String()
+	}
+	for _, k := range sortedKeys(span.Attributes) {
+		s.Attributes = append(s.Attributes, otlpAttribute{Key: k, Value: toOtlpValue(span.Attributes[k])})
+	}
+	if span.Err != nil {
+		// STATUS_CODE_ERROR
+		s.Status = otlpStatus{Code: 2, Message: span.Err.Error()}
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []otlpAttribute{{Key: "service.name", Value: otlpValue{"stringValue": serviceName}}},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "github.com/quintans/maze"},
+						"spans": []otlpSpan{s},
+					},
+				},
+			},
+		},
+	}
+}
+
+func toOtlpValue(v interface{}) otlpValue {
+	switch t := v.(type) {
+	case string:
+		return otlpValue{"stringValue": t}
+	case bool:
+		return otlpValue{"boolValue": t}
+	case int:
+		// OTLP/JSON encodes 64 bit integers as strings
+		return otlpValue{"intValue": strconv.Itoa(t)}
+	case int64:
+		return otlpValue{"intValue": strconv.FormatInt(t, 10)}
+	case float64:
+		return otlpValue{"doubleValue": t}
+	default:
+		return otlpValue{"stringValue": fmt.Sprint(v)}
+	}
+}
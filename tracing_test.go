@@ -0,0 +1,124 @@
+package maze
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	require.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	require.True(t, sc.Sampled)
+	require.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", sc.Traceparent())
+
+	// future versions can have more fields
+	_, err = ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+	require.NoError(t, err)
+
+	for _, s := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+	} {
+		_, err = ParseTraceparent(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	m := NewMaze()
+	m.Push("/*", Tracing(TracingConfig{Exporter: exporter, FilterSpans: true}))
+	m.GET("/greet/:Id", func(c IContext) error {
+		return c.Proceed()
+	}, func(c IContext) error {
+		require.Equal(t, CurrentSpan(c), SpanFrom(c.GetRequest().Context()))
+		return errors.New("failed")
+	})
+
+	r := httptest.NewRequest("GET", "/greet/1", nil)
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.Header.Set(TracestateHeader, "congo=t61rcWkgMzE")
+	m.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 3)
+	inner, outer, root := spans[0], spans[1], spans[2]
+	require.Equal(t, "GET /greet/:Id", root.Name)
+	require.Equal(t, SpanKindServer, root.Kind)
+	require.Equal(t, "00f067aa0ba902b7", root.ParentID.String())
+	require.Equal(t, "congo=t61rcWkgMzE", root.Context.TraceState)
+	require.Equal(t, http.StatusInternalServerError, root.Attributes["http.status_code"])
+	require.Error(t, root.Err)
+	require.Equal(t, "/greet/:Id", outer.Name)
+	require.Equal(t, root.Context.SpanID, outer.ParentID)
+	require.Equal(t, "/greet/:Id#2", inner.Name)
+	require.Equal(t, outer.Context.SpanID, inner.ParentID)
+	for _, s := range spans {
+		require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", s.Context.TraceID.String())
+	}
+
+	// not sampled
+	exporter.Reset()
+	r = httptest.NewRequest("GET", "/greet/1", nil)
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	m.ServeHTTP(httptest.NewRecorder(), r)
+	require.Empty(t, exporter.Spans())
+
+	require.Panics(t, func() {
+		Tracing(TracingConfig{})
+	})
+}
+
+func TestOTLPFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.json")
+	exporter, err := NewOTLPFileExporter(path, "greeter")
+	require.NoError(t, err)
+
+	m := NewMaze()
+	m.Push("/*", Tracing(TracingConfig{Exporter: exporter}))
+	m.GET("/*", func(c IContext) error {
+		return c.TEXT(http.StatusOK, "hi")
+	})
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	require.NoError(t, exporter.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var lines int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines++
+		var req struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []struct {
+						TraceID string `json:"traceId"`
+						Name    string `json:"name"`
+						Kind    int    `json:"kind"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &req))
+		span := req.ResourceSpans[0].ScopeSpans[0].Spans[0]
+		require.Equal(t, "GET /*", span.Name)
+		require.Equal(t, 2, span.Kind)
+		require.Len(t, span.TraceID, 32)
+	}
+	require.Equal(t, 1, lines)
+}
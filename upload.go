@@ -0,0 +1,178 @@
+package maze
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quintans/toolkit/web"
+)
+
+const (
+	UPLOAD_TOO_LARGE = "MAZE02"
+	UPLOAD_TOO_MANY  = "MAZE03"
+	UPLOAD_TYPE      = "MAZE04"
+	UPLOAD_INVALID   = "MAZE05"
+)
+
+// sniffLen is the number of bytes used by http.DetectContentType
+const sniffLen = 512
+
+// UploadConfig defines where and what files can be saved by SaveFiles
+type UploadConfig struct {
+	// Dir is where the files are saved.
+	// If empty, files are saved in the temporary directory and removed when the request ends.
+	Dir string
+	// MaxFileSize is the maximum size of each file. Zero means no limit.
+	MaxFileSize int64
+	// MaxFiles is the maximum number of files. Zero means no limit.
+	MaxFiles int
+	// AllowedTypes are the accepted media types, detected from the file content, eg: image/png or image/*.
+	// If empty, any type is accepted.
+	AllowedTypes []string
+}
+
+// UploadedFile describes a saved file
+type UploadedFile struct {
+	// Field is the form field name
+	Field string
+	// Filename is the file name sent by the client, without any directory
+	Filename string
+	// Path is where the file was saved
+	Path string
+	// Size is the file size in bytes
+	Size int64
+	// ContentType is the media type detected from the file content
+	ContentType string
+}
+
+// Parts calls fn for each part of a multipart request, as they are read from the body.
+func (c *MazeContext) Parts(fn func(*multipart.Part) error) error {
+	mr, err := c.Request.MultipartReader()
+	if err != nil {
+		return web.NewHttpFail(http.StatusBadRequest, UPLOAD_INVALID, err.Error())
+	}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = fn(p)
+		p.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SaveFiles streams the files of a multipart request to disk, returning the saved files and the other form values.
+// If any file breaks the configured limits, the files saved so far are removed.
+func (c *MazeContext) SaveFiles(cfg UploadConfig) ([]UploadedFile, Values, error) {
+	files := []UploadedFile{}
+	values := Values{}
+	err := c.Parts(func(p *multipart.Part) error {
+		if p.FileName() == "" {
+			b, err := ioutil.ReadAll(p)
+			if err != nil {
+				return err
+			}
+			values[p.FormName()] = append(values[p.FormName()], string(b))
+			return nil
+		}
+
+		if cfg.MaxFiles > 0 && len(files) >= cfg.MaxFiles {
+			return web.NewHttpFail(http.StatusRequestEntityTooLarge, UPLOAD_TOO_MANY, "too many files")
+		}
+		f, err := saveFile(p, cfg)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		for _, f := range files {
+			os.Remove(f.Path)
+		}
+		return nil, nil, err
+	}
+
+	if cfg.Dir == "" {
+		c.OnEnd(func() {
+			for _, f := range files {
+				os.Remove(f.Path)
+			}
+		})
+	}
+	return files, values, nil
+}
+
+func saveFile(p *multipart.Part, cfg UploadConfig) (UploadedFile, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(p, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return UploadedFile{}, err
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if !allowedType(contentType, cfg.AllowedTypes) {
+		return UploadedFile{}, web.NewHttpFail(http.StatusUnsupportedMediaType, UPLOAD_TYPE, "file type not allowed: "+contentType)
+	}
+
+	var r io.Reader = io.MultiReader(bytes.NewReader(head), p)
+	if cfg.MaxFileSize > 0 {
+		r = io.LimitReader(r, cfg.MaxFileSize+1)
+	}
+
+	filename := filepath.Base(p.FileName())
+	out, err := ioutil.TempFile(cfg.Dir, "upload-*"+filepath.Ext(filename))
+	if err != nil {
+		return UploadedFile{}, err
+	}
+	size, err := io.Copy(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && cfg.MaxFileSize > 0 && size > cfg.MaxFileSize {
+		err = web.NewHttpFail(http.StatusRequestEntityTooLarge, UPLOAD_TOO_LARGE, "file too large: "+filename)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return UploadedFile{}, err
+	}
+
+	return UploadedFile{
+		Field:       p.FormName(),
+		Filename:    filename,
+		Path:        out.Name(),
+		Size:        size,
+		ContentType: contentType,
+	}, nil
+}
+
+// allowedType checks if the media type matches any of the allowed ones.
+// An allowed type ending with /* matches any subtype.
+func allowedType(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		if a == mt || (strings.HasSuffix(a, "/*") && strings.HasPrefix(mt, a[:len(a)-1])) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package maze
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func multipartRequest(t *testing.T, files map[string]string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	require.NoError(t, mw.WriteField("name", "maze"))
+	for name, content := range files {
+		fw, err := mw.CreateFormFile("content", name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+	r := httptest.NewRequest("POST", "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestSaveFiles(t *testing.T) {
+	var saved []UploadedFile
+	m := NewMaze()
+	m.POST("/upload", func(c IContext) error {
+		files, values, err := c.SaveFiles(UploadConfig{
+			MaxFiles:     1,
+			MaxFileSize:  20,
+			AllowedTypes: []string{"text/*"},
+		})
+		if err != nil {
+			return err
+		}
+		saved = files
+		for _, f := range files {
+			require.FileExists(t, f.Path)
+		}
+		return c.TEXT(http.StatusOK, values.AsString("name"))
+	})
+
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, multipartRequest(t, map[string]string{"../notes.txt": "hello"}))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "maze", w.Body.String())
+	require.Len(t, saved, 1)
+	require.Equal(t, "notes.txt", saved[0].Filename)
+	require.Equal(t, int64(5), saved[0].Size)
+	require.Equal(t, "text/plain; charset=utf-8", saved[0].ContentType)
+	// temporary files are removed at the end of the request
+	require.NoFileExists(t, saved[0].Path)
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, multipartRequest(t, map[string]string{"a.txt": "a", "b.txt": "b"}))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, multipartRequest(t, map[string]string{"a.txt": "this text is too long for the limit"}))
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	w = httptest.NewRecorder()
+	m.ServeHTTP(w, multipartRequest(t, map[string]string{"a.png": "\x89PNG\x0D\x0A\x1A\x0A"}))
+	require.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
@@ -1,11 +1,27 @@
 package maze
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 )
 
-const ISO8601 = "2006-01-02T15:04:05.999999999Z0700"
+const (
+	ISO8601  = "2006-01-02T15:04:05.999999999Z0700"
+	DateOnly = "2006-01-02"
+	// UnixSeconds is a pseudo layout for the number of seconds since the epoch
+	UnixSeconds = "unix"
+	// UnixMillis is a pseudo layout for the number of milliseconds since the epoch
+	UnixMillis = "unixmilli"
+)
+
+// DefaultTimeLayouts are the layouts tried, in order, when converting to time.Time
+var DefaultTimeLayouts = []string{ISO8601, time.RFC3339, DateOnly}
 
 type Values map[string][]string
 
@@ -97,19 +113,229 @@ func (p Values) AsUint(k string) uint64 {
 	return 0
 }
 
-func (p Values) AsTimes(k string) []time.Time {
+// AsTimes converts every value, using the supplied layouts or DefaultTimeLayouts if none is supplied.
+// The layouts of the Maze are given by IContext.Converters().TimeLayouts().
+func (p Values) AsTimes(k string, layouts ...string) []time.Time {
 	var arr = make([]time.Time, 0)
 	for _, s := range p.AsStrings(k) {
-		if v, err := time.Parse(ISO8601, s); err == nil {
+		if v, err := ParseTime(s, layouts...); err == nil {
 			arr = append(arr, v)
 		}
 	}
 	return arr
 }
 
-func (p Values) AsTime(k string) time.Time {
-	if v, err := time.Parse(ISO8601, p.AsString(k)); err == nil {
+// AsTime converts the first value, using the supplied layouts or DefaultTimeLayouts if none is supplied.
+// The layouts of the Maze are given by IContext.Converters().TimeLayouts().
+func (p Values) AsTime(k string, layouts ...string) time.Time {
+	if v, err := ParseTime(p.AsString(k), layouts...); err == nil {
 		return v
 	}
 	return time.Time{}
 }
+
+func (p Values) AsDurations(k string) []time.Duration {
+	var arr = make([]time.Duration, 0)
+	for _, s := range p.AsStrings(k) {
+		if v, err := time.ParseDuration(s); err == nil {
+			arr = append(arr, v)
+		}
+	}
+	return arr
+}
+
+func (p Values) AsDuration(k string) time.Duration {
+	if v, err := time.ParseDuration(p.AsString(k)); err == nil {
+		return v
+	}
+	return 0
+}
+
+func (p Values) AsUUIDs(k string) []UUID {
+	var arr = make([]UUID, 0)
+	for _, s := range p.AsStrings(k) {
+		if v, err := ParseUUID(s); err == nil {
+			arr = append(arr, v)
+		}
+	}
+	return arr
+}
+
+func (p Values) AsUUID(k string) UUID {
+	if v, err := ParseUUID(p.AsString(k)); err == nil {
+		return v
+	}
+	return UUID{}
+}
+
+func (p Values) AsByteSizes(k string) []int64 {
+	var arr = make([]int64, 0)
+	for _, s := range p.AsStrings(k) {
+		if v, err := ParseByteSize(s); err == nil {
+			arr = append(arr, v)
+		}
+	}
+	return arr
+}
+
+// AsByteSize converts values like "512", "10KB" or "1.5MiB" into bytes
+func (p Values) AsByteSize(k string) int64 {
+	if v, err := ParseByteSize(p.AsString(k)); err == nil {
+		return v
+	}
+	return 0
+}
+
+// AsList splits every value by comma, eg: ?ids=1,2&ids=3 returns [1 2 3].
+// Empty elements are discarded.
+func (p Values) AsList(k string) []string {
+	var arr = make([]string, 0)
+	for _, s := range p.AsStrings(k) {
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				arr = append(arr, v)
+			}
+		}
+	}
+	return arr
+}
+
+// AsEnum returns the first value if it is one of the allowed values, otherwise an empty string
+func (p Values) AsEnum(k string, allowed ...string) string {
+	var v = p.AsString(k)
+	for _, a := range allowed {
+		if v == a {
+			return v
+		}
+	}
+	return ""
+}
+
+// ParseTime parses s with the first matching layout.
+// If no layout is supplied, DefaultTimeLayouts is used.
+func ParseTime(s string, layouts ...string) (time.Time, error) {
+	if len(layouts) == 0 {
+		layouts = DefaultTimeLayouts
+	}
+	for _, l := range layouts {
+		switch l {
+		case UnixSeconds, UnixMillis:
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				continue
+			}
+			if l == UnixSeconds {
+				return time.Unix(n, 0), nil
+			}
+			return time.Unix(n/1000, (n%1000)*int64(time.Millisecond)), nil
+		default:
+			if t, err := time.Parse(l, s); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time %q with layouts %v", s, layouts)
+}
+
+var byteUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"KB":  1000,
+	"MB":  1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"TB":  1000 * 1000 * 1000 * 1000,
+	"K":   1 << 10,
+	"M":   1 << 20,
+	"G":   1 << 30,
+	"T":   1 << 40,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+}
+
+// ParseByteSize parses a size with an optional unit.
+// KB, MB, ... are decimal units and K, KiB, M, MiB, ... are binary units.
+func ParseByteSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	})
+	num, unit := s, ""
+	if i != -1 {
+		num, unit = s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+	}
+	mult, ok := byteUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown byte size unit in %q", s)
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size %q: %w", s, err)
+	}
+	return int64(f * float64(mult)), nil
+}
+
+// UUID is a 128 bit universally unique identifier
+type UUID [16]byte
+
+// NewUUID creates a random (version 4) UUID
+func NewUUID() UUID {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(err)
+	}
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // variant 10
+	return u
+}
+
+// ParseUUID parses the canonical form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx.
+// The hyphenless form, with 32 hex digits, is also accepted.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) == 36 {
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return u, errors.New("invalid UUID format")
+		}
+		s = s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	}
+	if len(s) != 32 {
+		return u, errors.New("invalid UUID length")
+	}
+	if _, err := hex.Decode(u[:], []byte(s)); err != nil {
+		return u, fmt.Errorf("invalid UUID: %w", err)
+	}
+	return u, nil
+}
+
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[:8], u[:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+func (u UUID) IsZero() bool {
+	return u == UUID{}
+}
+
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *UUID) UnmarshalText(b []byte) error {
+	v, err := ParseUUID(string(b))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
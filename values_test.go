@@ -0,0 +1,102 @@
+package maze
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValues(t *testing.T) {
+	v := Values{
+		"d":    {"1m30s"},
+		"id":   {"6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		"size": {"10MB", "2KiB", "1.5k"},
+		"ids":  {"1, 2", "3,"},
+		"kind": {"blue"},
+		"at":   {"2021-03-04"},
+		"ms":   {"1614816000123"},
+	}
+
+	require.Equal(t, 90*time.Second, v.AsDuration("d"))
+	require.Equal(t, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", v.AsUUID("id").String())
+	require.True(t, v.AsUUID("d").IsZero())
+	require.Equal(t, []int64{10000000, 2048, 1536}, v.AsByteSizes("size"))
+	require.Equal(t, []string{"1", "2", "3"}, v.AsList("ids"))
+	require.Equal(t, "blue", v.AsEnum("kind", "red", "blue"))
+	require.Equal(t, "", v.AsEnum("kind", "red"))
+	require.Equal(t, time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), v.AsTime("at"))
+	require.True(t, v.AsTime("ms").IsZero())
+	require.Equal(t, int64(1614816000123), v.AsTime("ms", UnixMillis).UnixNano()/int64(time.Millisecond))
+}
+
+func TestConverters(t *testing.T) {
+	type level int
+	m := NewMaze(
+		WithTimeLayouts(UnixSeconds),
+		WithConverter(level(0), func(s string) (interface{}, error) {
+			return len(s), nil
+		}),
+	)
+
+	var q struct {
+		At    time.Time     `schema:"at"`
+		Every time.Duration `schema:"every"`
+		Id    UUID          `schema:"id"`
+		Level level         `schema:"level"`
+	}
+	r := httptest.NewRequest("GET", "/?at=1614816000&every=5s&id=6ba7b8109dad11d180b400c04fd430c8&level=high", nil)
+	ctx := NewContextWithMaze(m, httptest.NewRecorder(), r, nil)
+	require.NoError(t, ctx.QueryVars(&q))
+	require.Equal(t, int64(1614816000), q.At.Unix())
+	require.Equal(t, 5*time.Second, q.Every)
+	require.Equal(t, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", q.Id.String())
+	require.Equal(t, level(4), q.Level)
+
+	r = httptest.NewRequest("GET", "/?at=2021-03-04", nil)
+	ctx = NewContextWithMaze(m, httptest.NewRecorder(), r, nil)
+	require.Error(t, ctx.QueryVars(&q))
+}
+
+func TestContextTimeLayouts(t *testing.T) {
+	m := NewMaze(WithTimeLayouts(UnixSeconds))
+	m.GET("/events/:since", func(c IContext) error {
+		layouts := c.Converters().TimeLayouts()
+		require.Equal(t, int64(1614816000), c.Values().AsTime("at", layouts...).Unix())
+		require.Equal(t, int64(1614816001), c.PathValues().AsTime("since", layouts...).Unix())
+		require.Len(t, c.Values().AsTimes("at", layouts...), 1)
+		at, err := c.Converters().ParseTime(c.Values().AsString("at"))
+		require.NoError(t, err)
+		require.Equal(t, int64(1614816000), at.Unix())
+		return nil
+	})
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, httptest.NewRequest("GET", "/events/1614816001?at=1614816000", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+type appContext struct {
+	*MazeContext
+}
+
+func (c *appContext) Proceed() error {
+	return c.Next(c)
+}
+
+func TestContextFactory(t *testing.T) {
+	logger := newTestLogger()
+	m := NewMaze(WithLogger(logger), WithContextFactory(func(l Logger, w http.ResponseWriter, r *http.Request, filters []*Filter) IContext {
+		return &appContext{NewContext(l, w, r, filters)}
+	}))
+	m.GET("/*", func(c IContext) error {
+		_, ok := c.(*appContext)
+		require.True(t, ok)
+		c.Logger().Infof("hello")
+		return nil
+	})
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	lines := logger.Lines()
+	require.Equal(t, "hello", lines[len(lines)-1].msg)
+}